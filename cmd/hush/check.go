@@ -0,0 +1,116 @@
+package main
+
+import (
+    "fmt"
+    "os"
+    "strings"
+
+    "github.com/spf13/cobra"
+    "github.com/adith2005-20/hush/pkg/client"
+    "github.com/adith2005-20/hush/pkg/config"
+)
+
+var checkCmd = &cobra.Command{
+    Use:   "check",
+    Short: "Validate secrets against the schema in hush.yaml",
+    Long: `Validate an environment against the secrets: schema in hush.yaml without
+writing any files. Required keys must be set, and values must match their
+declared type and pattern. Keys the schema does not mention are listed as
+warnings.
+
+Use --env all to check every declared environment.`,
+    RunE: func(cmd *cobra.Command, args []string) error {
+        configs, err := config.LoadProjectConfigs(envFlag())
+        if err != nil {
+            return err
+        }
+
+        masterKey, err := config.LoadMasterKey()
+        if err != nil {
+            return fmt.Errorf("Error loading encryption key: %w", err)
+        }
+
+        var results []checkResult
+        var firstErr error
+        for _, cfg := range configs {
+            result := checkResult{Project: cfg.Project, Environment: cfg.Environment, Violations: []config.Violation{}}
+            target := cfg.Project + "/" + cfg.Environment
+            if len(cfg.Secrets) == 0 {
+                warn("%s: no secrets: schema in hush.yaml", target)
+                result.Valid = true
+                results = append(results, result)
+                continue
+            }
+
+            creds, err := config.LoadCredentials(profileFlag(), cfg)
+            if err != nil {
+                return withCode(exitAuth, err)
+            }
+
+            cli := client.New(creds.Server, creds.Token)
+            vars, err := fetchSecrets(cfg, cli, masterKey)
+            if err == nil {
+                vars, err = resolveRefs(cfg, cli, masterKey, vars)
+            }
+            if err != nil {
+                warn("%s: %v", target, err)
+                result.Error = err.Error()
+                if firstErr == nil {
+                    firstErr = err
+                }
+                results = append(results, result)
+                continue
+            }
+
+            values := make(map[string]string, len(vars))
+            for _, v := range vars {
+                values[v.Name] = v.Value
+            }
+            result.Violations = append(result.Violations, cfg.Secrets.Check(values)...)
+            for _, v := range vars {
+                if cfg.Secrets.Lookup(v.Name) == nil {
+                    result.Undeclared = append(result.Undeclared, v.Name)
+                }
+            }
+            result.Valid = len(result.Violations) == 0
+            results = append(results, result)
+
+            if !jsonOutput() {
+                for _, v := range result.Violations {
+                    fmt.Fprintf(os.Stderr, "❌ %s: %s\n", target, v)
+                }
+            }
+            for _, name := range result.Undeclared {
+                warn("%s: %s is not declared in the schema", target, name)
+            }
+            if result.Valid {
+                success("%s: all %d declared secrets are valid", target, len(cfg.Secrets))
+            } else if firstErr == nil {
+                firstErr = fmt.Errorf("%s does not match the schema in hush.yaml", target)
+            }
+        }
+
+        emit(results)
+        return firstErr
+    },
+}
+
+// checkResult is the JSON result of checking one environment.
+type checkResult struct {
+    Project     string             `json:"project"`
+    Environment string             `json:"environment"`
+    Valid       bool               `json:"valid"`
+    Violations  []config.Violation `json:"violations"`
+    Undeclared  []string           `json:"undeclared,omitempty"`
+    Error       string             `json:"error,omitempty"`
+}
+
+// schemaError reports every violation at once so they can all be fixed
+// before the next pull.
+func schemaError(violations []config.Violation) error {
+    lines := make([]string, len(violations))
+    for i, v := range violations {
+        lines[i] = "  • " + v.String()
+    }
+    return fmt.Errorf("secrets do not match the schema in hush.yaml:\n%s", strings.Join(lines, "\n"))
+}
@@ -0,0 +1,119 @@
+package main
+
+import (
+    "fmt"
+    "os"
+    "strings"
+    "text/tabwriter"
+
+    "github.com/spf13/cobra"
+    "github.com/adith2005-20/hush/pkg/config"
+)
+
+var configCmd = &cobra.Command{
+    Use:   "config",
+    Short: "Inspect client configuration",
+}
+
+var configShowCmd = &cobra.Command{
+    Use:   "show",
+    Short: "Show effective settings and where each one came from",
+    Long: `Show the effective configuration and the source of every value.
+
+Precedence, highest first:
+  1. Environment variables (HUSH_SERVER, HUSH_TOKEN, HUSH_MASTER_KEY,
+     HUSH_PROJECT, HUSH_ENV, HUSH_CONFIG_DIR, HUSH_PROFILE), after the
+     --env and --profile flags
+  2. hush.yaml in the project (the nearest one in this directory or a
+     parent, and the monorepo root for settings a sub-project leaves unset)
+  3. credentials.yaml and master.key in the config directory
+  4. Built-in defaults`,
+    RunE: func(cmd *cobra.Command, args []string) error {
+        var rows []settingRow
+        add := func(name, value, source string) {
+            rows = append(rows, settingRow{name, value, source})
+        }
+
+        cfg, err := loadProjectConfig()
+        if err != nil {
+            add("project", "-", err.Error())
+            cfg = nil
+        } else {
+            settings := []struct{ name, value string }{
+                {"project", cfg.Project},
+                {"environment", cfg.Environment},
+                {"profile", cfg.Profile},
+                {"output.path", cfg.OutputPath()},
+                {"output.format", cfg.Output.Format},
+                {"output.files", cfg.FilesPath()},
+                {"prefix", cfg.Prefix},
+                {"include", strings.Join(cfg.Include, ", ")},
+            }
+            if cfg.Protected {
+                settings = append(settings, struct{ name, value string }{"protected", "true"})
+            }
+            for _, s := range settings {
+                if s.value != "" {
+                    add(s.name, s.value, cfg.Sources[s.name])
+                }
+            }
+        }
+
+        creds, err := config.LoadCredentials(profileFlag(), cfg)
+        if err != nil {
+            add("server", "-", err.Error())
+        } else {
+            add("server", creds.Server, creds.Sources["server"])
+            add("token", maskToken(creds.Token), creds.Sources["token"])
+        }
+
+        if key, err := config.LoadMasterKey(); err != nil {
+            add("master key", "-", err.Error())
+        } else {
+            add("master key", fmt.Sprintf("%d bytes", len(key)), config.MasterKeySource())
+        }
+
+        dir, err := config.GetConfigDir()
+        source := config.SourceDefault
+        if os.Getenv(config.EnvConfigDir) != "" {
+            source = config.EnvConfigDir
+        }
+        if err != nil {
+            add("config dir", "-", err.Error())
+        } else {
+            add("config dir", dir, source)
+        }
+
+        if jsonOutput() {
+            emit(rows)
+            return nil
+        }
+
+        w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+        fmt.Fprintln(w, "SETTING\tVALUE\tSOURCE")
+        for _, r := range rows {
+            fmt.Fprintf(w, "%s\t%s\t%s\n", r.Setting, r.Value, r.Source)
+        }
+        w.Flush()
+        return nil
+    },
+}
+
+// settingRow is one line of hush config show.
+type settingRow struct {
+    Setting string `json:"setting"`
+    Value   string `json:"value"`
+    Source  string `json:"source"`
+}
+
+// maskToken shows just enough of a token to tell tokens apart.
+func maskToken(token string) string {
+    if len(token) <= 12 {
+        return "****"
+    }
+    return token[:9] + "…" + token[len(token)-4:]
+}
+
+func init() {
+    configCmd.AddCommand(configShowCmd)
+}
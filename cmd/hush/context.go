@@ -0,0 +1,111 @@
+package main
+
+import (
+    "fmt"
+    "strings"
+
+    "github.com/spf13/cobra"
+    "github.com/adith2005-20/hush/pkg/config"
+)
+
+var contextCmd = &cobra.Command{
+    Use:   "context",
+    Short: "Switch between saved server profiles",
+}
+
+// profileEntry is one profile in the JSON output of the context commands.
+type profileEntry struct {
+    Name    string `json:"name"`
+    Server  string `json:"server"`
+    Current bool   `json:"current"`
+}
+
+var contextListCmd = &cobra.Command{
+    Use:   "list",
+    Short: "List saved profiles",
+    RunE: func(cmd *cobra.Command, args []string) error {
+        store, err := config.LoadCredentialStore()
+        if err != nil {
+            return err
+        }
+
+        entries := []profileEntry{}
+        for _, name := range store.Names() {
+            entries = append(entries, profileEntry{name, store.Profiles[name].Server, name == store.Current})
+        }
+        if jsonOutput() {
+            emit(entries)
+            return nil
+        }
+
+        if len(entries) == 0 {
+            info("No profiles saved")
+            info("\nAdd one with:")
+            info("  hush login http://server:55555 your-token --profile name")
+            return nil
+        }
+
+        for _, e := range entries {
+            marker := " "
+            if e.Current {
+                marker = "*"
+            }
+            fmt.Printf("%s %-12s %s\n", marker, e.Name, e.Server)
+        }
+        return nil
+    },
+}
+
+var contextUseCmd = &cobra.Command{
+    Use:   "use PROFILE",
+    Short: "Make a profile the current one",
+    Args:  cobra.ExactArgs(1),
+    RunE: func(cmd *cobra.Command, args []string) error {
+        store, err := config.LoadCredentialStore()
+        if err != nil {
+            return err
+        }
+
+        creds, ok := store.Profiles[args[0]]
+        if !ok {
+            return withCode(exitNotFound, fmt.Errorf("No profile named %q (available: %s)",
+                args[0], strings.Join(store.Names(), ", ")))
+        }
+
+        store.Current = args[0]
+        if err := store.Save(); err != nil {
+            return fmt.Errorf("Failed to save credentials: %w", err)
+        }
+
+        success("Switched to %s (%s)", args[0], creds.Server)
+        emit(profileEntry{args[0], creds.Server, true})
+        return nil
+    },
+}
+
+var contextCurrentCmd = &cobra.Command{
+    Use:   "current",
+    Short: "Show the profile commands in this directory will use",
+    RunE: func(cmd *cobra.Command, args []string) error {
+        // Inside a project the binding in hush.yaml takes part in the choice.
+        cfg, _ := loadProjectConfig()
+
+        creds, err := config.LoadCredentials(profileFlag(), cfg)
+        if err != nil {
+            return withCode(exitAuth, err)
+        }
+
+        if jsonOutput() {
+            emit(map[string]string{"profile": creds.Profile, "server": creds.Server})
+            return nil
+        }
+        fmt.Printf("%s (%s)\n", creds.Profile, creds.Server)
+        return nil
+    },
+}
+
+func init() {
+    contextCmd.AddCommand(contextListCmd)
+    contextCmd.AddCommand(contextUseCmd)
+    contextCmd.AddCommand(contextCurrentCmd)
+}
@@ -0,0 +1,172 @@
+package main
+
+import (
+    "bufio"
+    "errors"
+    "fmt"
+    "os"
+    "strings"
+
+    "github.com/spf13/cobra"
+    "github.com/adith2005-20/hush/pkg/client"
+    "github.com/adith2005-20/hush/pkg/config"
+)
+
+// envFlag returns the --env value, or "" when not given.
+func envFlag() string {
+    env, _ := rootCmd.PersistentFlags().GetString("env")
+    return env
+}
+
+// loadProjectConfig loads hush.yaml for the environment chosen by --env,
+// HUSH_ENV or hush.yaml, targeting the shared group named by --group if
+// one is.
+func loadProjectConfig() (*config.Config, error) {
+    if group := groupFlag(); group != "" {
+        return config.LoadGroupConfig(group, envFlag())
+    }
+    return config.LoadProjectConfig(envFlag())
+}
+
+// confirmProtected asks before a command acts on an environment marked
+// protected: true, and fails unless the user types its name back. --yes
+// skips the prompt; without a terminal to ask on, the command refuses
+// instead.
+func confirmProtected(cfg *config.Config) error {
+    if !cfg.Protected {
+        return nil
+    }
+    if yes, _ := rootCmd.PersistentFlags().GetBool("yes"); yes {
+        return nil
+    }
+
+    if !stdinIsTerminal() {
+        return fmt.Errorf("%s/%s is protected; pass --yes to confirm", cfg.Project, cfg.Environment)
+    }
+
+    // The prompt goes to stderr so it never mixes with results.
+    fmt.Fprintf(os.Stderr, "⚠️  %s/%s is protected. Type %q to continue: ", cfg.Project, cfg.Environment, cfg.Environment)
+    answer, _ := bufio.NewReader(os.Stdin).ReadString('\n')
+    if strings.TrimSpace(answer) != cfg.Environment {
+        return errors.New("Aborted")
+    }
+    return nil
+}
+
+var inheritCmd = &cobra.Command{
+    Use:   "inherit [PARENT]",
+    Short: "Make the environment inherit secrets from another",
+    Long: `Make the current environment read every secret it does not set itself
+from PARENT, which can inherit in turn. Environments that share most of
+their keys then only hold what differs:
+
+  hush inherit base --env staging
+  hush inherit base --env production
+  hush set LOG_LEVEL=debug --env staging   # overrides base in staging only
+  hush unset LOG_LEVEL --env staging       # staging reads base's value again
+
+The server merges inherited secrets into every read, so pull, run, get and
+list see them, and 'hush list' shows where each key comes from.
+
+Without PARENT, print the environment's chain of parents. --none stops the
+environment inheriting.`,
+    Args: cobra.MaximumNArgs(1),
+    RunE: func(cmd *cobra.Command, args []string) error {
+        none, _ := cmd.Flags().GetBool("none")
+        if none && len(args) > 0 {
+            return withCode(exitUsage, errors.New("give either PARENT or --none"))
+        }
+
+        cfg, err := loadProjectConfig()
+        if err != nil {
+            return err
+        }
+
+        creds, err := config.LoadCredentials(profileFlag(), cfg)
+        if err != nil {
+            return withCode(exitAuth, err)
+        }
+        cli := client.New(creds.Server, creds.Token)
+
+        if len(args) == 0 && !none {
+            chain, err := parentChain(cli, cfg.Project, cfg.Environment)
+            if err != nil {
+                return fmt.Errorf("Error reading %s: %w", cfg.Environment, err)
+            }
+            // Quiet mode prints the parent alone, or nothing.
+            switch {
+            case jsonOutput():
+            case quiet():
+                if len(chain) > 1 {
+                    fmt.Println(chain[1])
+                }
+            case len(chain) == 1:
+                fmt.Printf("%s/%s does not inherit from another environment\n", cfg.Project, cfg.Environment)
+            default:
+                fmt.Printf("%s/%s inherits from %s\n", cfg.Project, cfg.Environment, strings.Join(chain[1:], " → "))
+            }
+            emit(inheritResult{Project: cfg.Project, Environment: cfg.Environment, Chain: chain[1:]})
+            return nil
+        }
+
+        parent := ""
+        if len(args) > 0 {
+            parent = args[0]
+        }
+        if parent == cfg.Environment {
+            return withCode(exitUsage, fmt.Errorf("%s cannot inherit from itself", parent))
+        }
+
+        if err := confirmProtected(cfg); err != nil {
+            return err
+        }
+        if err := cli.SetParent(cfg.Project, cfg.Environment, parent); err != nil {
+            return fmt.Errorf("Error updating %s: %w", cfg.Environment, err)
+        }
+
+        chain, err := parentChain(cli, cfg.Project, cfg.Environment)
+        if err != nil {
+            return fmt.Errorf("Error reading %s: %w", cfg.Environment, err)
+        }
+        if parent == "" {
+            success("%s/%s no longer inherits", cfg.Project, cfg.Environment)
+        } else {
+            success("%s/%s inherits from %s", cfg.Project, cfg.Environment, strings.Join(chain[1:], " → "))
+        }
+        emit(inheritResult{Project: cfg.Project, Environment: cfg.Environment, Chain: chain[1:]})
+        return nil
+    },
+}
+
+// inheritResult is the JSON result of hush inherit.
+type inheritResult struct {
+    Project     string   `json:"project"`
+    Environment string   `json:"environment"`
+    // Chain lists the environments inherited from, nearest first.
+    Chain       []string `json:"chain"`
+}
+
+// parentChain returns env followed by the environments it inherits from,
+// nearest first.
+func parentChain(cli *client.Client, project, env string) ([]string, error) {
+    chain := []string{env}
+    for {
+        parent, err := cli.GetParent(project, chain[len(chain)-1])
+        if err != nil {
+            return nil, err
+        }
+        if parent == "" {
+            return chain, nil
+        }
+        for _, e := range chain {
+            if e == parent {
+                return nil, fmt.Errorf("inheritance cycle through %s", parent)
+            }
+        }
+        chain = append(chain, parent)
+    }
+}
+
+func init() {
+    inheritCmd.Flags().Bool("none", false, "Stop the environment inheriting")
+}
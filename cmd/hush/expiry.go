@@ -0,0 +1,301 @@
+package main
+
+import (
+    "errors"
+    "fmt"
+    "math"
+    "os"
+    "sort"
+    "time"
+
+    "github.com/spf13/cobra"
+    "github.com/adith2005-20/hush/pkg/client"
+    "github.com/adith2005-20/hush/pkg/config"
+    "github.com/adith2005-20/hush/pkg/crypto"
+)
+
+// serverTime is the format hushd stores and reports times in, always UTC.
+const serverTime = "2006-01-02 15:04:05"
+
+// defaultExpiryWarning is how far ahead list and status flag secrets that
+// are due, matching hushd's default.
+const defaultExpiryWarning = 14 * 24 * time.Hour
+
+// expiry is when a secret is due for replacement: a fixed date, a rotation
+// interval counted from each update, or both. The zero value has neither.
+type expiry struct {
+    At    time.Time
+    Every time.Duration
+}
+
+func addExpiryFlags(cmd *cobra.Command) {
+    cmd.Flags().String("expires", "", "Expiry date (YYYY-MM-DD or RFC 3339) or time from now (e.g. 90d)")
+    cmd.Flags().String("rotate-every", "", "Rotation interval counted from each update (e.g. 90d, 12w)")
+    cmd.Flags().Bool("no-expiry", false, "Remove the expiry date and rotation interval")
+}
+
+// expiryFlags returns the expiry given with --expires, --rotate-every or
+// --no-expiry, or nil to keep whatever the secret already has.
+func expiryFlags(cmd *cobra.Command) (*expiry, error) {
+    expires, _ := cmd.Flags().GetString("expires")
+    every, _ := cmd.Flags().GetString("rotate-every")
+    none, _ := cmd.Flags().GetBool("no-expiry")
+
+    if none {
+        if expires != "" || every != "" {
+            return nil, withCode(exitUsage, errors.New("--no-expiry cannot be combined with --expires or --rotate-every"))
+        }
+        return &expiry{}, nil
+    }
+    if expires == "" && every == "" {
+        return nil, nil
+    }
+
+    e := &expiry{}
+    if expires != "" {
+        at, err := parseExpiry(expires)
+        if err != nil {
+            return nil, withCode(exitUsage, err)
+        }
+        e.At = at
+    }
+    if every != "" {
+        d, err := config.ParseDuration(every)
+        if err != nil {
+            return nil, withCode(exitUsage, err)
+        }
+        if d < time.Second {
+            return nil, withCode(exitUsage, fmt.Errorf("--rotate-every %s is too short", every))
+        }
+        e.Every = d
+    }
+    return e, nil
+}
+
+// parseExpiry accepts a date, taken as midnight local time, an RFC 3339
+// time or a duration from now.
+func parseExpiry(s string) (time.Time, error) {
+    if t, err := time.ParseInLocation("2006-01-02", s, time.Local); err == nil {
+        return t, nil
+    }
+    if t, err := time.Parse(time.RFC3339, s); err == nil {
+        return t, nil
+    }
+    if d, err := config.ParseDuration(s); err == nil {
+        return time.Now().Add(d), nil
+    }
+    return time.Time{}, fmt.Errorf("invalid expiry %q (use YYYY-MM-DD, an RFC 3339 time or a duration such as 90d)", s)
+}
+
+// currentValue fetches and decrypts key as set in cfg's own environment,
+// file name included, so it can be stored again with a new expiry or
+// metadata.
+func currentValue(cfg *config.Config, cli *client.Client, masterKey []byte, key string) (envVar, error) {
+    secret, err := cli.GetSecret(cfg.Project, cfg.Environment, serverKey(cfg, masterKey, key))
+    if err == nil && secret.InheritedFrom != "" {
+        err = fmt.Errorf("%s is inherited from %s; give a value to override it", key, secret.InheritedFrom)
+        return envVar{}, withCode(exitNotFound, err)
+    }
+    if errors.Is(err, client.ErrNotFound) {
+        return envVar{}, withCode(exitNotFound, fmt.Errorf("%s is not set; give it a value first", key))
+    }
+    if err != nil {
+        return envVar{}, err
+    }
+
+    value, err := crypto.Decrypt(secret.Value, masterKey)
+    if err != nil {
+        return envVar{}, withCode(exitDecrypt, err)
+    }
+    file, err := secretFile(*secret, masterKey)
+    if err != nil {
+        return envVar{}, withCode(exitDecrypt, err)
+    }
+    return envVar{Name: key, Value: value, File: file, Mode: os.FileMode(secret.FileMode)}, nil
+}
+
+// apply sets the expiry fields of a secret about to be stored.
+func (e expiry) apply(secret *client.Secret) {
+    secret.ExpiresAt, secret.RotateEvery = "", 0
+    if !e.At.IsZero() {
+        secret.ExpiresAt = e.At.UTC().Format(serverTime)
+    }
+    if e.Every > 0 {
+        secret.RotateEvery = int64(e.Every / time.Second)
+    }
+}
+
+// Expiry states of a secret relative to now and the warning window.
+const (
+    stateOK       = "ok"
+    stateExpiring = "expiring"
+    stateExpired  = "expired"
+)
+
+// dueState parses a due_at reported by hushd and classifies it. It returns
+// "" for secrets without an expiry.
+func dueState(dueAt string, now time.Time, warning time.Duration) (string, time.Time) {
+    due, err := time.ParseInLocation(serverTime, dueAt, time.UTC)
+    if err != nil {
+        return "", time.Time{}
+    }
+    switch {
+    case !due.After(now):
+        return stateExpired, due
+    case due.Before(now.Add(warning)):
+        return stateExpiring, due
+    }
+    return stateOK, due
+}
+
+// describeDue says when due is relative to now, in whole days.
+func describeDue(due, now time.Time) string {
+    days := int(math.Round(due.Sub(now).Hours() / 24))
+    switch {
+    case days == 0 && due.After(now):
+        return "expires today"
+    case days == 0:
+        return "expired today"
+    case days == 1:
+        return "expires tomorrow"
+    case days == -1:
+        return "expired yesterday"
+    case days > 0:
+        return fmt.Sprintf("expires in %d days", days)
+    }
+    return fmt.Sprintf("expired %d days ago", -days)
+}
+
+// statusEntry is one secret with an expiry in the JSON result of hush
+// status.
+type statusEntry struct {
+    Key           string `json:"key"`
+    State         string `json:"state"`
+    DueAt         string `json:"due_at"`
+    ExpiresAt     string `json:"expires_at,omitempty"`
+    RotateEvery   int64  `json:"rotate_every,omitempty"`
+    InheritedFrom string `json:"inherited_from,omitempty"`
+    secretMeta
+}
+
+// statusResult is the JSON result of hush status.
+type statusResult struct {
+    Project     string        `json:"project"`
+    Environment string        `json:"environment"`
+    Count       int           `json:"count"`
+    Expired     int           `json:"expired"`
+    Expiring    int           `json:"expiring"`
+    Secrets     []statusEntry `json:"secrets"`
+}
+
+var statusCmd = &cobra.Command{
+    Use:   "status",
+    Short: "Show which secrets have expired or are due for rotation",
+    Long: `Show every secret in the environment that has an expiry date or a
+rotation interval, soonest first, flagging those that have expired or are
+due within --within (default 14d).
+
+Set them with 'hush set' or 'hush generate':
+
+  hush set STRIPE_KEY --stdin --expires 2026-12-31
+  hush generate DB_PASSWORD --rotate-every 90d
+
+hushd can alert on them too: see 'hushd expiring' and the secret.expiring
+and secret.expired webhook events.`,
+    Args: cobra.NoArgs,
+    RunE: func(cmd *cobra.Command, args []string) error {
+        within, _ := cmd.Flags().GetString("within")
+        warning, err := config.ParseDuration(within)
+        if err != nil {
+            return withCode(exitUsage, err)
+        }
+
+        cfg, err := loadProjectConfig()
+        if err != nil {
+            return err
+        }
+
+        creds, err := config.LoadCredentials(profileFlag(), cfg)
+        if err != nil {
+            return withCode(exitAuth, err)
+        }
+
+        cli := client.New(creds.Server, creds.Token)
+        secrets, err := cli.GetSecrets(cfg.Project, cfg.Environment)
+        if err != nil {
+            return fmt.Errorf("Error: %w", err)
+        }
+
+        var masterKey []byte
+        if hasOpaqueKeys(secrets) {
+            if masterKey, err = config.LoadMasterKey(); err != nil {
+                return fmt.Errorf("Error loading encryption key: %w", err)
+            }
+        }
+
+        now := time.Now()
+        result := statusResult{Project: cfg.Project, Environment: cfg.Environment, Count: len(secrets), Secrets: []statusEntry{}}
+        dues := map[string]time.Time{}
+        for _, secret := range secrets {
+            state, due := dueState(secret.DueAt, now, warning)
+            if state == "" {
+                continue
+            }
+            name, err := secretName(secret, masterKey)
+            if err != nil {
+                name = secret.Key
+            }
+            meta, _ := secretMetadata(secret, masterKey)
+            switch state {
+            case stateExpired:
+                result.Expired++
+            case stateExpiring:
+                result.Expiring++
+            }
+            dues[name] = due
+            result.Secrets = append(result.Secrets, statusEntry{
+                Key:           name,
+                State:         state,
+                DueAt:         secret.DueAt,
+                ExpiresAt:     secret.ExpiresAt,
+                RotateEvery:   secret.RotateEvery,
+                InheritedFrom: secret.InheritedFrom,
+                secretMeta:    meta,
+            })
+        }
+        sort.SliceStable(result.Secrets, func(i, j int) bool { return result.Secrets[i].DueAt < result.Secrets[j].DueAt })
+
+        if jsonOutput() {
+            emit(result)
+            return nil
+        }
+
+        info("%s/%s: %d secrets, %d with an expiry", cfg.Project, cfg.Environment, result.Count, len(result.Secrets))
+        if result.Expired > 0 || result.Expiring > 0 {
+            warn("%d expired, %d due within %s", result.Expired, result.Expiring, within)
+        }
+        for _, entry := range result.Secrets {
+            if quiet() {
+                if entry.State != stateOK {
+                    fmt.Println(entry.Key)
+                }
+                continue
+            }
+            mark := map[string]string{stateOK: "✓", stateExpiring: "⚠️ ", stateExpired: "❌"}[entry.State]
+            due := dues[entry.Key]
+            line := fmt.Sprintf("  %s %s  %s (%s)", mark, entry.Key, describeDue(due, now), due.Local().Format("2006-01-02"))
+            if entry.InheritedFrom != "" {
+                line += ", inherited from " + entry.InheritedFrom
+            }
+            if entry.Owner != "" {
+                line += ", owner: " + entry.Owner
+            }
+            fmt.Println(line)
+        }
+        return nil
+    },
+}
+
+func init() {
+    statusCmd.Flags().String("within", "14d", "Flag secrets due within this long")
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+    "encoding/json"
+    "fmt"
+    "os"
+    "strings"
+
+    "gopkg.in/yaml.v3"
+)
+
+// envVar is one decrypted secret as written to an output file.
+type envVar struct {
+    Name  string
+    Value string
+    // File is set for file secrets, which are written out as a file of
+    // that name and Mode rather than inlined.
+    File  string
+    Mode  os.FileMode
+    // Expiry and Meta are stored with the secret by putSecret; nil keeps
+    // what the secret already has.
+    Expiry *expiry
+    Meta   *metaChange
+}
+
+// formatSecrets renders vars in one of the output formats hush.yaml accepts:
+// dotenv, export (a shell script to source), json or yaml.
+func formatSecrets(format string, vars []envVar) ([]byte, error) {
+    switch format {
+    case "", "dotenv":
+        var out strings.Builder
+        for _, v := range vars {
+            fmt.Fprintf(&out, "%s=%s\n", v.Name, dotenvValue(v.Value))
+        }
+        return []byte(out.String()), nil
+    case "export":
+        var out strings.Builder
+        for _, v := range vars {
+            fmt.Fprintf(&out, "export %s=%s\n", v.Name, shellQuote(v.Value))
+        }
+        return []byte(out.String()), nil
+    case "json":
+        data, err := json.MarshalIndent(varMap(vars), "", "  ")
+        if err != nil {
+            return nil, err
+        }
+        return append(data, '\n'), nil
+    case "yaml":
+        return yaml.Marshal(varMap(vars))
+    }
+    return nil, fmt.Errorf("unknown output format %q (use dotenv, export, json or yaml)", format)
+}
+
+func varMap(vars []envVar) map[string]string {
+    m := make(map[string]string, len(vars))
+    for _, v := range vars {
+        m[v.Name] = v.Value
+    }
+    return m
+}
+
+// dotenvValue leaves single-line values bare and double-quotes multi-line
+// ones, such as PEM blocks, escaping newlines the way dotenv parsers expect.
+func dotenvValue(s string) string {
+    if !strings.ContainsAny(s, "\r\n") {
+        return s
+    }
+    r := strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`, "\r", `\r`)
+    return `"` + r.Replace(s) + `"`
+}
+
+// shellQuote wraps s in single quotes so a POSIX shell reads it verbatim.
+func shellQuote(s string) string {
+    return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
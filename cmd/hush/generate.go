@@ -0,0 +1,320 @@
+package main
+
+import (
+    "crypto/ecdh"
+    "crypto/ed25519"
+    "crypto/rand"
+    "crypto/rsa"
+    "crypto/x509"
+    "encoding/base64"
+    "encoding/hex"
+    "encoding/pem"
+    "errors"
+    "fmt"
+    "math/big"
+    "sort"
+    "strings"
+
+    "github.com/google/uuid"
+    "github.com/spf13/cobra"
+    "github.com/adith2005-20/hush/pkg/client"
+    "github.com/adith2005-20/hush/pkg/config"
+)
+
+// charsets are the named character sets --charset accepts. Anything else
+// is taken as the literal characters to draw from. symbols leaves out $, {
+// and } so a generated value never reads as a ${...} reference.
+var charsets = map[string]string{
+    "alnum":   "ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz0123456789",
+    "alpha":   "ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz",
+    "lower":   "abcdefghijklmnopqrstuvwxyz",
+    "digits":  "0123456789",
+    "hex":     "0123456789abcdef",
+    "symbols": "ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz0123456789!#%&()*+,-./:;<=>?@[]^_|~",
+}
+
+const defaultCharset = "alnum"
+
+// generator makes a value of one --type. Key pairs set public as well.
+type generator struct {
+    // length is the default --length, and zero for types of fixed size.
+    length   int
+    generate func(length int, charset string) (private, public string, err error)
+}
+
+var generators = map[string]generator{
+    "password": {32, func(n int, charset string) (string, string, error) {
+        s, err := randomString(n, charset)
+        return s, "", err
+    }},
+    "hex": {32, func(n int, _ string) (string, string, error) {
+        b, err := randomBytes(n)
+        return hex.EncodeToString(b), "", err
+    }},
+    "base64": {32, func(n int, _ string) (string, string, error) {
+        b, err := randomBytes(n)
+        return base64.StdEncoding.EncodeToString(b), "", err
+    }},
+    // RFC 7518 requires an HS256 key of at least 256 bits.
+    "jwt-hs256": {32, func(n int, _ string) (string, string, error) {
+        if n < 32 {
+            return "", "", withCode(exitUsage, errors.New("jwt-hs256 keys need at least 32 bytes"))
+        }
+        b, err := randomBytes(n)
+        return base64.RawURLEncoding.EncodeToString(b), "", err
+    }},
+    "uuid": {0, func(int, string) (string, string, error) {
+        id, err := uuid.NewRandom()
+        return id.String(), "", err
+    }},
+    "ed25519": {0, func(int, string) (string, string, error) {
+        pub, priv, err := ed25519.GenerateKey(rand.Reader)
+        if err != nil {
+            return "", "", err
+        }
+        return pemPair(priv, pub)
+    }},
+    "x25519": {0, func(int, string) (string, string, error) {
+        priv, err := ecdh.X25519().GenerateKey(rand.Reader)
+        if err != nil {
+            return "", "", err
+        }
+        return pemPair(priv, priv.PublicKey())
+    }},
+    "rsa": {3072, func(n int, _ string) (string, string, error) {
+        if n < 2048 {
+            return "", "", withCode(exitUsage, errors.New("rsa keys need at least 2048 bits"))
+        }
+        priv, err := rsa.GenerateKey(rand.Reader, n)
+        if err != nil {
+            return "", "", err
+        }
+        return pemPair(priv, &priv.PublicKey)
+    }},
+}
+
+var generateCmd = &cobra.Command{
+    Use:   "generate KEY",
+    Short: "Generate a random secret or key pair and store it",
+    Long: `Generate a value with crypto/rand and store it encrypted, exactly as
+'hush set' would. The value itself is never printed.
+
+Types and what --length counts:
+  password   characters from --charset (default 32, alnum)
+  hex        random bytes, hex-encoded (default 32)
+  base64     random bytes, base64-encoded (default 32)
+  jwt-hs256  random bytes, base64url-encoded HMAC key (default and minimum 32)
+  uuid       a random (version 4) UUID
+  ed25519    key pair
+  x25519     key pair
+  rsa        key pair of --length bits (default 3072)
+
+Key pairs are stored as two secrets, KEY_PRIVATE (PKCS #8) and KEY_PUBLIC
+(PKIX), both PEM-encoded. --print-public writes the public half to stdout
+so it can be handed out.
+
+Existing secrets are not replaced unless --force is given. --rotate-every
+and --expires record when the value is due for replacement (see 'hush
+status'), and --desc, --owner, --tag and --link describe it as for 'hush
+set'.`,
+    Args: cobra.ExactArgs(1),
+    RunE: func(cmd *cobra.Command, args []string) error {
+        kind, _ := cmd.Flags().GetString("type")
+        length, _ := cmd.Flags().GetInt("length")
+        charset, _ := cmd.Flags().GetString("charset")
+        printPublic, _ := cmd.Flags().GetBool("print-public")
+        force, _ := cmd.Flags().GetBool("force")
+
+        gen, ok := generators[kind]
+        if !ok {
+            return withCode(exitUsage, fmt.Errorf("unknown type %q (use %s)", kind, strings.Join(generatorTypes(), ", ")))
+        }
+        if cmd.Flags().Changed("length") && gen.length == 0 {
+            return withCode(exitUsage, fmt.Errorf("%s values have a fixed length", kind))
+        }
+        if !cmd.Flags().Changed("length") {
+            length = gen.length
+        }
+        if cmd.Flags().Changed("charset") && kind != "password" {
+            return withCode(exitUsage, errors.New("--charset only applies to --type password"))
+        }
+        exp, err := expiryFlags(cmd)
+        if err != nil {
+            return err
+        }
+        meta, err := metadataFlags(cmd)
+        if err != nil {
+            return err
+        }
+
+        cfg, err := loadProjectConfig()
+        if err != nil {
+            return err
+        }
+
+        creds, err := config.LoadCredentials(profileFlag(), cfg)
+        if err != nil {
+            return withCode(exitAuth, err)
+        }
+
+        masterKey, err := config.LoadMasterKey()
+        if err != nil {
+            return fmt.Errorf("Error loading encryption key: %w", err)
+        }
+
+        private, public, err := gen.generate(length, charset)
+        if err != nil {
+            return fmt.Errorf("Error generating %s: %w", args[0], err)
+        }
+        if printPublic && public == "" {
+            return withCode(exitUsage, fmt.Errorf("%s is not a key pair; --print-public needs ed25519, x25519 or rsa", kind))
+        }
+
+        values := []envVar{{Name: args[0], Value: private, Expiry: exp, Meta: meta}}
+        if public != "" {
+            values = []envVar{
+                {Name: args[0] + "_PRIVATE", Value: private, Expiry: exp, Meta: meta},
+                {Name: args[0] + "_PUBLIC", Value: public, Expiry: exp, Meta: meta},
+            }
+        }
+
+        if err := confirmProtected(cfg); err != nil {
+            return err
+        }
+        cli := client.New(creds.Server, creds.Token)
+
+        // Remember what each key held, so a key pair that cannot be stored
+        // whole is put back as it was. Overriding an inherited value leaves
+        // the parent alone.
+        previous := make([]*client.Secret, len(values))
+        for i, v := range values {
+            secret, err := cli.GetSecret(cfg.Project, cfg.Environment, serverKey(cfg, masterKey, v.Name))
+            if err != nil && !errors.Is(err, client.ErrNotFound) {
+                return fmt.Errorf("Error checking %s: %w", v.Name, err)
+            }
+            if err == nil && secret.InheritedFrom == "" {
+                if !force {
+                    return withCode(exitConflict, fmt.Errorf("%s is already set in %s/%s; pass --force to replace it", v.Name, cfg.Project, cfg.Environment))
+                }
+                previous[i] = secret
+            }
+        }
+
+        result := generateResult{keyResult: keyResult{Project: cfg.Project, Environment: cfg.Environment}}
+        for i, v := range values {
+            if err := putSecret(cfg, cli, masterKey, v); err != nil {
+                undoGenerate(cfg, cli, masterKey, values[:i], previous[:i])
+                return err
+            }
+            result.Changed = append(result.Changed, v.Name)
+        }
+        // With --print-public, stdout carries only the public key.
+        if !printPublic {
+            for _, name := range result.Changed {
+                success("Generated %s (%s)", name, kind)
+            }
+        }
+
+        if printPublic {
+            result.Public = public
+            if !jsonOutput() {
+                fmt.Print(public)
+            }
+        }
+        emit(result)
+        return nil
+    },
+}
+
+// generateResult is the JSON result of hush generate.
+type generateResult struct {
+    keyResult
+    Public string `json:"public,omitempty"`
+}
+
+// undoGenerate puts back the secrets a failed hush generate already
+// stored, so a key pair is never left with one new half. Whatever cannot
+// be put back is reported.
+func undoGenerate(cfg *config.Config, cli *client.Client, masterKey []byte, stored []envVar, previous []*client.Secret) {
+    for i, v := range stored {
+        var err error
+        if old := previous[i]; old != nil {
+            old.Project, old.Env = cfg.Project, cfg.Environment
+            err = cli.PutSecret(*old)
+        } else {
+            err = cli.DeleteSecret(cfg.Project, cfg.Environment, serverKey(cfg, masterKey, v.Name))
+        }
+        if err != nil {
+            warn("%s was stored but could not be put back: %v", v.Name, err)
+        }
+    }
+}
+
+func generatorTypes() []string {
+    var types []string
+    for t := range generators {
+        types = append(types, t)
+    }
+    sort.Strings(types)
+    return types
+}
+
+// randomString returns length characters drawn uniformly from charset,
+// which is a name from charsets or a literal set of characters.
+func randomString(length int, charset string) (string, error) {
+    chars := []rune(charset)
+    if named, ok := charsets[charset]; ok {
+        chars = []rune(named)
+    }
+    if len(chars) < 2 {
+        return "", withCode(exitUsage, fmt.Errorf("charset %q needs at least two characters", charset))
+    }
+    if length < 1 {
+        return "", withCode(exitUsage, fmt.Errorf("length must be at least 1"))
+    }
+
+    out := make([]rune, length)
+    max := big.NewInt(int64(len(chars)))
+    for i := range out {
+        n, err := rand.Int(rand.Reader, max)
+        if err != nil {
+            return "", err
+        }
+        out[i] = chars[n.Int64()]
+    }
+    return string(out), nil
+}
+
+func randomBytes(n int) ([]byte, error) {
+    if n < 1 {
+        return nil, withCode(exitUsage, fmt.Errorf("length must be at least 1"))
+    }
+    b := make([]byte, n)
+    _, err := rand.Read(b)
+    return b, err
+}
+
+// pemPair encodes a key pair as PEM, the private half as PKCS #8 and the
+// public half as PKIX.
+func pemPair(private, public interface{}) (string, string, error) {
+    privDER, err := x509.MarshalPKCS8PrivateKey(private)
+    if err != nil {
+        return "", "", err
+    }
+    pubDER, err := x509.MarshalPKIXPublicKey(public)
+    if err != nil {
+        return "", "", err
+    }
+    return string(pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: privDER})),
+        string(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: pubDER})), nil
+}
+
+func init() {
+    generateCmd.Flags().String("type", "password", "Kind of value: "+strings.Join(generatorTypes(), ", "))
+    generateCmd.Flags().Int("length", 0, "Length of the value; what it counts depends on --type")
+    generateCmd.Flags().String("charset", defaultCharset, "Characters for passwords: alnum, alpha, lower, digits, hex, symbols or a literal set")
+    generateCmd.Flags().Bool("print-public", false, "Print the public half of a key pair to stdout")
+    generateCmd.Flags().Bool("force", false, "Replace secrets that are already set")
+    addExpiryFlags(generateCmd)
+    addMetadataFlags(generateCmd)
+}
@@ -0,0 +1,186 @@
+package main
+
+import (
+    "encoding/json"
+    "errors"
+    "fmt"
+    "os"
+    "os/exec"
+    "strings"
+
+    "github.com/spf13/cobra"
+    "github.com/adith2005-20/hush/pkg/client"
+    "github.com/adith2005-20/hush/pkg/config"
+    "github.com/adith2005-20/hush/pkg/crypto"
+)
+
+// clipboardEnv names the command --copy pipes values into, e.g.
+// "xclip -selection clipboard". Without it a known clipboard tool is used.
+const clipboardEnv = "HUSH_CLIPBOARD"
+
+var getCmd = &cobra.Command{
+    Use:   "get KEY [KEY2 ...]",
+    Short: "Print the decrypted value of one or more secrets",
+    Long: `Print decrypted secret values to stdout, one per line, with nothing else
+on stdout so the output can be captured by scripts:
+
+  export DATABASE_URL="$(hush get DATABASE_URL)"
+
+Only the requested keys are fetched from the server. Nothing is printed
+unless every key is found.
+
+It exits with 4 if a key is not set and 3 if the server rejects the token
+(see 'hush --help' for all exit codes).`,
+    Args: cobra.MinimumNArgs(1),
+    RunE: func(cmd *cobra.Command, args []string) error {
+        asJSON, _ := cmd.Flags().GetBool("json")
+        quote, _ := cmd.Flags().GetBool("quote")
+        copyOut, _ := cmd.Flags().GetBool("copy")
+        raw, _ := cmd.Flags().GetBool("raw")
+        asJSON = asJSON || jsonOutput()
+
+        cfg, err := loadProjectConfig()
+        if err != nil {
+            return err
+        }
+        if err := confirmProtected(cfg); err != nil {
+            return err
+        }
+
+        creds, err := config.LoadCredentials(profileFlag(), cfg)
+        if err != nil {
+            return withCode(exitAuth, err)
+        }
+
+        masterKey, err := config.LoadMasterKey()
+        if err != nil {
+            return fmt.Errorf("Error loading encryption key: %w", err)
+        }
+
+        cli := client.New(creds.Server, creds.Token)
+
+        values := make([]string, len(args))
+        files := make([]string, len(args))
+        var missing []string
+        for i, key := range args {
+            secret, err := lookupSecret(cfg, cli, masterKey, key)
+            if errors.Is(err, client.ErrNotFound) {
+                warn("%s is not set in %s/%s", key, cfg.Project, cfg.Environment)
+                missing = append(missing, key)
+                continue
+            }
+            if err != nil {
+                return fmt.Errorf("Error fetching %s: %w", key, err)
+            }
+
+            if values[i], err = crypto.Decrypt(secret.Value, masterKey); err != nil {
+                return withCode(exitDecrypt, fmt.Errorf("Error decrypting %s: %w", key, err))
+            }
+            files[i] = secret.File
+        }
+        if len(missing) > 0 {
+            return withCode(exitNotFound, fmt.Errorf("not set: %s", strings.Join(missing, ", ")))
+        }
+
+        if !raw {
+            r := newResolver(cfg, cli, masterKey)
+            for i, key := range args {
+                k := refKey{cfg.Project, cfg.Environment, key}
+                r.add(k, envVar{Name: key, Value: values[i], File: files[i]})
+                if values[i], err = r.resolve(k); err != nil {
+                    return err
+                }
+            }
+        }
+
+        var out strings.Builder
+        switch {
+        case asJSON:
+            m := make(map[string]string, len(args))
+            for i, key := range args {
+                m[key] = values[i]
+            }
+            data, _ := json.MarshalIndent(m, "", "  ")
+            out.Write(data)
+            out.WriteByte('\n')
+        case quote:
+            for _, v := range values {
+                out.WriteString(shellQuote(v) + "\n")
+            }
+        case len(values) == 1 && !copyOut:
+            // A single binary value or file secret is printed exactly as
+            // stored, so it can be redirected to a file.
+            if data, ok := decodeBinary(values[0]); ok {
+                os.Stdout.Write(data)
+                return nil
+            }
+            if files[0] != "" {
+                fmt.Print(values[0])
+                return nil
+            }
+            out.WriteString(values[0] + "\n")
+        default:
+            for _, v := range values {
+                out.WriteString(v + "\n")
+            }
+        }
+
+        if copyOut {
+            // A single raw value is copied without its trailing newline.
+            text := out.String()
+            if len(values) == 1 && !asJSON {
+                text = strings.TrimSuffix(text, "\n")
+            }
+            if err := copyToClipboard(text); err != nil {
+                return err
+            }
+            if !quiet() {
+                fmt.Fprintf(os.Stderr, "✓ Copied %s to the clipboard\n", strings.Join(args, ", "))
+            }
+            return nil
+        }
+
+        fmt.Print(out.String())
+        return nil
+    },
+}
+
+// copyToClipboard pipes text into $HUSH_CLIPBOARD, or the first clipboard
+// tool found on the PATH.
+func copyToClipboard(text string) error {
+    var argv []string
+    if v := os.Getenv(clipboardEnv); v != "" {
+        argv = strings.Fields(v)
+    } else {
+        for _, candidate := range [][]string{
+            {"pbcopy"},
+            {"wl-copy"},
+            {"xclip", "-selection", "clipboard"},
+            {"xsel", "--clipboard", "--input"},
+            {"clip.exe"},
+        } {
+            if _, err := exec.LookPath(candidate[0]); err == nil {
+                argv = candidate
+                break
+            }
+        }
+    }
+    if len(argv) == 0 {
+        return fmt.Errorf("no clipboard command found; set %s (e.g. \"xclip -selection clipboard\")", clipboardEnv)
+    }
+
+    c := exec.Command(argv[0], argv[1:]...)
+    c.Stdin = strings.NewReader(text)
+    c.Stderr = os.Stderr
+    if err := c.Run(); err != nil {
+        return fmt.Errorf("%s failed: %w", argv[0], err)
+    }
+    return nil
+}
+
+func init() {
+    getCmd.Flags().Bool("json", false, "Print a JSON object of key to value")
+    getCmd.Flags().Bool("quote", false, "Quote values for use in a POSIX shell")
+    getCmd.Flags().Bool("raw", false, "Print values as stored, without resolving ${...} references")
+    getCmd.Flags().Bool("copy", false, "Copy to the clipboard instead of printing (see "+clipboardEnv+")")
+}
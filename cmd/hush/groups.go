@@ -0,0 +1,169 @@
+package main
+
+import (
+    "errors"
+    "fmt"
+
+    "github.com/spf13/cobra"
+    "github.com/adith2005-20/hush/pkg/client"
+    "github.com/adith2005-20/hush/pkg/config"
+)
+
+// groupCommands are the commands --group applies to.
+var groupCommands = map[string]bool{
+    "set": true, "unset": true, "get": true, "list": true, "generate": true,
+    "inherit": true,
+}
+
+var groupsCmd = &cobra.Command{
+    Use:   "groups",
+    Short: "List shared secret groups",
+    Long: `List the shared groups on the server. A group holds secrets used by
+several projects, such as SMTP credentials or a Sentry DSN, and is edited
+with --group on set, unset, get, list and generate:
+
+  hush set --group shared-smtp SMTP_HOST=smtp.example.com
+  hush list --group shared-smtp --env production
+
+Projects include groups in hush.yaml:
+
+  include: [shared-smtp, sentry]
+
+pull, run, render and check merge the included groups' secrets from the
+same environment into the project's. The project's own secrets take
+precedence over every group, and a group listed earlier over one listed
+later; a key two groups set to different values is reported. Changing a
+group reaches every project that includes it on its next pull.`,
+    Args: cobra.NoArgs,
+    RunE: func(cmd *cobra.Command, args []string) error {
+        // Groups can be listed outside a project, on the current profile.
+        cfg, err := loadProjectConfig()
+        if errors.Is(err, config.ErrNoProjectConfig) {
+            cfg, err = nil, nil
+        }
+        if err != nil {
+            return err
+        }
+
+        creds, err := config.LoadCredentials(profileFlag(), cfg)
+        if err != nil {
+            return withCode(exitAuth, err)
+        }
+
+        groups, err := client.New(creds.Server, creds.Token).ListGroups()
+        if err != nil {
+            return fmt.Errorf("Error listing groups: %w", err)
+        }
+
+        included := map[string]bool{}
+        if cfg != nil {
+            for _, name := range cfg.Include {
+                included[name] = true
+            }
+        }
+
+        entries := []groupEntry{}
+        for _, name := range groups {
+            entries = append(entries, groupEntry{Name: name, Included: included[name]})
+        }
+
+        if jsonOutput() {
+            emit(entries)
+            return nil
+        }
+        if len(entries) == 0 {
+            info("No groups found")
+            info("\nCreate one with:")
+            info("  hush set --group NAME KEY=value")
+            return nil
+        }
+
+        info("Groups on %s:", creds.Server)
+        for _, entry := range entries {
+            switch {
+            case quiet():
+                fmt.Println(entry.Name)
+            case entry.Included:
+                fmt.Printf("  • %s (included)\n", entry.Name)
+            default:
+                fmt.Printf("  • %s\n", entry.Name)
+            }
+        }
+        return nil
+    },
+}
+
+// groupEntry is one group in the JSON result of hush groups.
+type groupEntry struct {
+    Name     string `json:"name"`
+    Included bool   `json:"included"`
+}
+
+// groupFlag returns the --group value, or "" when not given.
+func groupFlag() string {
+    group, _ := rootCmd.PersistentFlags().GetString("group")
+    return group
+}
+
+// mergeGroups adds the secrets of the groups cfg includes to vars, its own
+// secrets. Those take precedence over every group, and an earlier group
+// over a later one. A key two groups set to different values is reported,
+// since only the order of include: decides between them.
+func mergeGroups(cfg *config.Config, cli *client.Client, masterKey []byte, vars []envVar) ([]envVar, error) {
+    groups, err := cfg.IncludedGroups()
+    if err != nil {
+        return nil, withCode(exitUsage, err)
+    }
+
+    // from records the group each key came from, "" for the project.
+    from := make(map[string]string, len(vars))
+    index := make(map[string]int, len(vars))
+    for i, v := range vars {
+        from[v.Name], index[v.Name] = "", i
+    }
+
+    for _, group := range groups {
+        name := group.GroupName()
+        groupVars, err := fetchEnvironment(group, cli, masterKey)
+        if err != nil {
+            return nil, fmt.Errorf("group %s: %w", name, err)
+        }
+        if len(groupVars) == 0 {
+            warn("Group %s has no secrets in %s", name, cfg.Environment)
+        }
+
+        for _, v := range groupVars {
+            owner, ok := from[v.Name]
+            if !ok {
+                from[v.Name], index[v.Name] = name, len(vars)
+                vars = append(vars, v)
+                continue
+            }
+            if owner != "" && vars[index[v.Name]].Value != v.Value {
+                warn("%s is set by groups %s and %s; using %s", v.Name, owner, name, owner)
+            }
+        }
+    }
+    return vars, nil
+}
+
+// lookupSecret fetches one secret by name from cfg's project, falling back
+// to the groups it includes in order.
+func lookupSecret(cfg *config.Config, cli *client.Client, masterKey []byte, key string) (*client.Secret, error) {
+    secret, err := cli.GetSecret(cfg.Project, cfg.Environment, serverKey(cfg, masterKey, key))
+    if !errors.Is(err, client.ErrNotFound) {
+        return secret, err
+    }
+
+    groups, groupErr := cfg.IncludedGroups()
+    if groupErr != nil {
+        return nil, withCode(exitUsage, groupErr)
+    }
+    for _, group := range groups {
+        secret, groupErr := cli.GetSecret(group.Project, group.Environment, key)
+        if !errors.Is(groupErr, client.ErrNotFound) {
+            return secret, groupErr
+        }
+    }
+    return nil, err
+}
@@ -0,0 +1,193 @@
+package main
+
+import (
+    "bytes"
+    "encoding/base64"
+    "errors"
+    "fmt"
+    "io"
+    "os"
+    "path/filepath"
+    "strconv"
+    "strings"
+    "unicode/utf8"
+
+    "github.com/spf13/cobra"
+)
+
+// binaryPrefix marks a value holding base64-encoded bytes, for file contents
+// that are not UTF-8 text (a DER certificate, a Java keystore). pull writes
+// such values to their own file under output.files.
+const binaryPrefix = "hush:base64:"
+
+// encodeValue returns data as a secret value, base64-encoding it behind
+// binaryPrefix unless it is plain text.
+func encodeValue(data []byte) string {
+    if utf8.Valid(data) && bytes.IndexByte(data, 0) < 0 {
+        return string(data)
+    }
+    return binaryPrefix + base64.StdEncoding.EncodeToString(data)
+}
+
+// decodeBinary returns the bytes of a value written by encodeValue, and
+// false for ordinary text values.
+func decodeBinary(value string) ([]byte, bool) {
+    if !strings.HasPrefix(value, binaryPrefix) {
+        return nil, false
+    }
+    data, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(value, binaryPrefix))
+    if err != nil {
+        return nil, false
+    }
+    return data, true
+}
+
+// readStdin reads a value piped to hush. A single trailing newline, as left
+// by echo or a heredoc, is not part of the value.
+func readStdin() (string, error) {
+    data, err := io.ReadAll(os.Stdin)
+    if err != nil {
+        return "", fmt.Errorf("Error reading stdin: %w", err)
+    }
+    value := encodeValue(data)
+    if !strings.HasPrefix(value, binaryPrefix) {
+        value = strings.TrimSuffix(strings.TrimSuffix(value, "\n"), "\r")
+    }
+    return value, nil
+}
+
+// readValueFile reads a value from path exactly as stored on disk.
+func readValueFile(path string) (string, error) {
+    data, err := os.ReadFile(path)
+    if err != nil {
+        return "", err
+    }
+    return encodeValue(data), nil
+}
+
+// promptValue asks for key's value twice on the terminal without echoing
+// it. It fails when stdin is not a terminal.
+func promptValue(key string) (string, error) {
+    if !stdinIsTerminal() {
+        return "", withCode(exitUsage, fmt.Errorf("no value for %s: use %s=VALUE, --stdin, --from-file or --generate", key, key))
+    }
+
+    fmt.Fprintf(os.Stderr, "Value for %s: ", key)
+    value, err := readHidden(os.Stdin)
+    fmt.Fprintln(os.Stderr)
+    if err != nil {
+        return "", fmt.Errorf("Error reading value: %w", err)
+    }
+    if value == "" {
+        return "", fmt.Errorf("no value entered for %s", key)
+    }
+
+    fmt.Fprintf(os.Stderr, "Confirm %s: ", key)
+    confirm, err := readHidden(os.Stdin)
+    fmt.Fprintln(os.Stderr)
+    if err != nil {
+        return "", fmt.Errorf("Error reading value: %w", err)
+    }
+    if confirm != value {
+        return "", errors.New("values did not match")
+    }
+    return value, nil
+}
+
+func stdinIsTerminal() bool {
+    return isTerminal(os.Stdin)
+}
+
+// fileFlags validates --as-file, --file-name and --mode, returning the file
+// name and permissions of a file secret, or "" when --as-file is not set.
+func fileFlags(cmd *cobra.Command, asFile bool, fromFile string) (string, os.FileMode, error) {
+    name, _ := cmd.Flags().GetString("file-name")
+    perm, _ := cmd.Flags().GetString("mode")
+    if !asFile {
+        if cmd.Flags().Changed("file-name") || cmd.Flags().Changed("mode") {
+            return "", 0, withCode(exitUsage, errors.New("--file-name and --mode need --as-file"))
+        }
+        return "", 0, nil
+    }
+
+    if name == "" && fromFile != "" {
+        name = filepath.Base(fromFile)
+    }
+    if name == "" {
+        return "", 0, withCode(exitUsage, errors.New("--as-file with --stdin needs --file-name"))
+    }
+    if name != filepath.Base(name) || name == "." || name == ".." {
+        return "", 0, withCode(exitUsage, fmt.Errorf("file name %q must not contain a directory", name))
+    }
+
+    mode, err := strconv.ParseUint(perm, 8, 32)
+    if err != nil || mode&^0700 != 0 || mode&0400 == 0 {
+        return "", 0, withCode(exitUsage, fmt.Errorf("mode %s must be readable by the owner only, e.g. 0600 or 0400", perm))
+    }
+    return name, os.FileMode(mode), nil
+}
+
+// secretFileName returns the name of the file pull writes v to, for file
+// secrets and binary values.
+func secretFileName(v envVar) (string, bool) {
+    if v.File != "" {
+        return v.File, true
+    }
+    if _, binary := decodeBinary(v.Value); binary {
+        return v.Name, true
+    }
+    return "", false
+}
+
+// writeFiles writes file secrets and binary values in vars to dir and
+// returns vars with each of their values replaced by the file's absolute
+// path. File secrets are left out of the result unless refs is set.
+func writeFiles(dir string, vars []envVar, refs bool) ([]envVar, error) {
+    var out []envVar
+    written := map[string]string{}
+    for _, v := range vars {
+        data, binary := decodeBinary(v.Value)
+        if v.File == "" && !binary {
+            out = append(out, v)
+            continue
+        }
+        if !binary {
+            data = []byte(v.Value)
+        }
+
+        name, _ := secretFileName(v)
+        mode := v.Mode
+        if mode == 0 {
+            mode = 0600
+        }
+        // Names and modes come from the server; don't let them escape dir
+        // or loosen permissions.
+        if name != filepath.Base(name) || name == "." || name == ".." {
+            return nil, fmt.Errorf("Refusing to write %s: file name %q must not contain a directory", v.Name, name)
+        }
+        if mode&^0700 != 0 {
+            return nil, fmt.Errorf("Refusing to write %s: mode %04o gives access beyond the owner", v.Name, mode)
+        }
+        if other, ok := written[name]; ok {
+            return nil, withCode(exitConflict, fmt.Errorf("%s and %s both write the file %s", other, v.Name, name))
+        }
+        written[name] = v.Name
+
+        if err := os.MkdirAll(dir, 0700); err != nil {
+            return nil, fmt.Errorf("Error creating %s: %w", dir, err)
+        }
+        path, err := filepath.Abs(filepath.Join(dir, name))
+        if err != nil {
+            return nil, err
+        }
+        if err := writeFileAtomic(path, data, mode); err != nil {
+            return nil, fmt.Errorf("Error writing %s: %w", path, err)
+        }
+
+        if v.File == "" || refs {
+            v.Value = path
+            out = append(out, v)
+        }
+    }
+    return out, nil
+}
@@ -0,0 +1,245 @@
+package main
+
+import (
+    "errors"
+    "fmt"
+    "regexp"
+    "strings"
+
+    "github.com/adith2005-20/hush/pkg/client"
+    "github.com/adith2005-20/hush/pkg/config"
+    "github.com/adith2005-20/hush/pkg/crypto"
+)
+
+// refPattern matches ${KEY} and ${ref:project/environment/KEY}, and the
+// escape $${...}, which stands for a literal ${...}.
+var refPattern = regexp.MustCompile(`\$?\$\{([^}]*)\}`)
+
+// refKeyPattern is what ${...} must hold to be a reference to a key in the
+// same environment. Anything else, like the shell's ${PORT:-3000}, is left
+// as written.
+var refKeyPattern = regexp.MustCompile(`^[A-Za-z0-9_.-]+$`)
+
+// refKey identifies a secret anywhere on the server.
+type refKey struct {
+    project, env, key string
+}
+
+func (k refKey) String() string {
+    return k.project + "/" + k.env + "/" + k.key
+}
+
+// resolver expands references between secrets after they are decrypted,
+// so the server never sees resolved values. Secrets not handed to it up
+// front are fetched with the client's token, which leaves access to other
+// projects and environments to the server.
+type resolver struct {
+    cfg       *config.Config
+    cli       *client.Client
+    masterKey []byte
+
+    raw      map[refKey]string
+    literal  map[refKey]bool
+    resolved map[refKey]string
+    // listed caches the environments of other projects, by project and
+    // environment with an empty key.
+    listed map[refKey][]client.Secret
+    // stack holds the secrets being expanded, to report cycles.
+    stack []refKey
+}
+
+// newResolver returns a resolver for references made from cfg's project
+// and environment.
+func newResolver(cfg *config.Config, cli *client.Client, masterKey []byte) *resolver {
+    return &resolver{
+        cfg:       cfg,
+        cli:       cli,
+        masterKey: masterKey,
+        raw:       map[refKey]string{},
+        literal:   map[refKey]bool{},
+        resolved:  map[refKey]string{},
+        listed:    map[refKey][]client.Secret{},
+    }
+}
+
+// add makes a decrypted value known to the resolver. File secrets and
+// binary values are never expanded.
+func (r *resolver) add(k refKey, v envVar) {
+    r.raw[k] = v.Value
+    _, binary := decodeBinary(v.Value)
+    r.literal[k] = v.File != "" || binary
+}
+
+// resolveRefs expands the references in vars, which belong to cfg's
+// project and environment. Schema defaults count as values.
+func resolveRefs(cfg *config.Config, cli *client.Client, masterKey []byte, vars []envVar) ([]envVar, error) {
+    r := newResolver(cfg, cli, masterKey)
+    for _, spec := range cfg.Secrets {
+        if spec.Default != "" {
+            r.add(refKey{cfg.Project, cfg.Environment, spec.Name}, envVar{Name: spec.Name, Value: spec.Default})
+        }
+    }
+    for _, v := range vars {
+        r.add(refKey{cfg.Project, cfg.Environment, v.Name}, v)
+    }
+
+    for i, v := range vars {
+        value, err := r.resolve(refKey{cfg.Project, cfg.Environment, v.Name})
+        if err != nil {
+            return nil, err
+        }
+        vars[i].Value = value
+    }
+    return vars, nil
+}
+
+func (r *resolver) resolve(k refKey) (string, error) {
+    if v, ok := r.resolved[k]; ok {
+        return v, nil
+    }
+    for i, active := range r.stack {
+        if active == k {
+            var path []string
+            for _, s := range r.stack[i:] {
+                path = append(path, s.key)
+            }
+            return "", fmt.Errorf("reference cycle: %s -> %s", strings.Join(path, " -> "), k.key)
+        }
+    }
+
+    if _, ok := r.raw[k]; !ok {
+        if err := r.fetch(k); err != nil {
+            return "", err
+        }
+    }
+    raw := r.raw[k]
+    if r.literal[k] || !strings.Contains(raw, "${") {
+        r.resolved[k] = raw
+        return raw, nil
+    }
+
+    r.stack = append(r.stack, k)
+    defer func() { r.stack = r.stack[:len(r.stack)-1] }()
+
+    var firstErr error
+    value := refPattern.ReplaceAllStringFunc(raw, func(m string) string {
+        if strings.HasPrefix(m, "$$") {
+            return m[1:]
+        }
+        if firstErr != nil {
+            return m
+        }
+        target, ok, err := parseRef(refPattern.FindStringSubmatch(m)[1], k)
+        if err != nil {
+            firstErr = err
+            return m
+        }
+        if !ok {
+            return m
+        }
+        v, err := r.resolve(target)
+        if err != nil {
+            firstErr = err
+            return m
+        }
+        return v
+    })
+    if firstErr != nil {
+        // Name the outermost secret being resolved, since that is the one
+        // the user asked for.
+        if len(r.stack) == 1 {
+            return "", fmt.Errorf("Error resolving %s: %w", k.key, firstErr)
+        }
+        return "", firstErr
+    }
+
+    r.resolved[k] = value
+    return value, nil
+}
+
+// parseRef returns the secret a reference inside from points at. It
+// reports false for ${...} that is not a reference at all, which stays as
+// written.
+func parseRef(ref string, from refKey) (refKey, bool, error) {
+    if rest, ok := strings.CutPrefix(ref, "ref:"); ok {
+        parts := strings.Split(rest, "/")
+        if len(parts) != 3 || parts[0] == "" || parts[1] == "" || parts[2] == "" {
+            return refKey{}, false, fmt.Errorf("invalid reference ${%s} (use ${ref:project/environment/KEY})", ref)
+        }
+        return refKey{parts[0], parts[1], parts[2]}, true, nil
+    }
+    if !refKeyPattern.MatchString(ref) {
+        return refKey{}, false, nil
+    }
+    return refKey{from.project, from.env, ref}, true, nil
+}
+
+// fetch loads a referenced secret that was not handed to the resolver.
+// Keys in the current environment are looked up as hush get would, with
+// included groups and schema defaults; other environments of the project
+// by their server key. Other projects may encrypt their key names, so
+// their environment is listed and matched here rather than sending the
+// name to the server.
+func (r *resolver) fetch(k refKey) error {
+    var secret *client.Secret
+    var err error
+    switch {
+    case k.project == r.cfg.Project && k.env == r.cfg.Environment:
+        secret, err = lookupSecret(r.cfg, r.cli, r.masterKey, k.key)
+        if errors.Is(err, client.ErrNotFound) {
+            if spec := r.cfg.Secrets.Lookup(k.key); spec != nil && spec.Default != "" {
+                r.add(k, envVar{Name: k.key, Value: spec.Default})
+                return nil
+            }
+        }
+    case k.project == r.cfg.Project:
+        secret, err = r.cli.GetSecret(k.project, k.env, serverKey(r.cfg, r.masterKey, k.key))
+    default:
+        secret, err = r.find(k)
+    }
+    if errors.Is(err, client.ErrNotFound) {
+        return withCode(exitNotFound, fmt.Errorf("${%s} is not set", r.refName(k)))
+    }
+    if err != nil {
+        return fmt.Errorf("Error fetching %s: %w", k, err)
+    }
+
+    value, err := crypto.Decrypt(secret.Value, r.masterKey)
+    if err != nil {
+        return withCode(exitDecrypt, fmt.Errorf("Error decrypting %s: %w", k, err))
+    }
+    r.add(k, envVar{Name: k.key, Value: value, File: secret.File})
+    return nil
+}
+
+// find looks k up in a listing of its environment, decrypting opaque key
+// names. Names that cannot be decrypted belong to someone else's key and
+// are passed over.
+func (r *resolver) find(k refKey) (*client.Secret, error) {
+    env := refKey{k.project, k.env, ""}
+    secrets, ok := r.listed[env]
+    if !ok {
+        var err error
+        if secrets, err = r.cli.GetSecrets(k.project, k.env); err != nil {
+            return nil, err
+        }
+        r.listed[env] = secrets
+    }
+    for i := range secrets {
+        if name, err := secretName(secrets[i], r.masterKey); err == nil && name == k.key {
+            return &secrets[i], nil
+        }
+    }
+    return nil, fmt.Errorf("secret %s %w", k.key, client.ErrNotFound)
+}
+
+// refName spells k the way a reference from the current secret would.
+func (r *resolver) refName(k refKey) string {
+    if len(r.stack) > 0 {
+        from := r.stack[len(r.stack)-1]
+        if from.project == k.project && from.env == k.env {
+            return k.key
+        }
+    }
+    return "ref:" + k.String()
+}
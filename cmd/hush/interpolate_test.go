@@ -0,0 +1,180 @@
+package main
+
+import (
+    "encoding/json"
+    "net/http"
+    "net/http/httptest"
+    "strings"
+    "testing"
+
+    "github.com/adith2005-20/hush/pkg/client"
+    "github.com/adith2005-20/hush/pkg/config"
+    "github.com/adith2005-20/hush/pkg/crypto"
+)
+
+var testMasterKey = make([]byte, 32)
+
+// notFoundServer answers every request with 404, so references to keys
+// not handed to the resolver are reported as not set.
+func notFoundServer(t *testing.T) *client.Client {
+    srv := httptest.NewServer(http.NotFoundHandler())
+    t.Cleanup(srv.Close)
+    return client.New(srv.URL, "token")
+}
+
+func TestResolveRefs(t *testing.T) {
+    tests := []struct {
+        name    string
+        vars    []envVar
+        schema  config.Schema
+        want    map[string]string
+        wantErr string
+    }{
+        {
+            name: "same environment",
+            vars: []envVar{{Name: "HOST", Value: "db"}, {Name: "URL", Value: "pg://${HOST}/app"}},
+            want: map[string]string{"URL": "pg://db/app"},
+        },
+        {
+            name: "chained",
+            vars: []envVar{{Name: "A", Value: "${B}-${B}"}, {Name: "B", Value: "<${C}>"}, {Name: "C", Value: "c"}},
+            want: map[string]string{"A": "<c>-<c>", "B": "<c>"},
+        },
+        {
+            name: "escapes",
+            vars: []envVar{{Name: "A", Value: "1"}, {Name: "B", Value: "$${A} $${ref:p/e/K} $${PORT:-1} ${A}"}},
+            want: map[string]string{"B": "${A} ${ref:p/e/K} ${PORT:-1} 1"},
+        },
+        {
+            name: "not references",
+            vars: []envVar{{Name: "A", Value: "${PORT:-3000} ${} ${a b} ${x/y} $A {A}"}},
+            want: map[string]string{"A": "${PORT:-3000} ${} ${a b} ${x/y} $A {A}"},
+        },
+        {
+            name:   "schema default",
+            vars:   []envVar{{Name: "URL", Value: "${HOST}:${PORT}"}, {Name: "HOST", Value: "db"}},
+            schema: config.Schema{{Name: "PORT", Default: "5432"}, {Name: "HOST", Default: "localhost"}},
+            want:   map[string]string{"URL": "db:5432"},
+        },
+        {
+            name: "file secret stays literal",
+            vars: []envVar{{Name: "A", Value: "1"}, {Name: "CERT", Value: "${A}", File: "cert.pem"}, {Name: "B", Value: "${CERT}"}},
+            want: map[string]string{"CERT": "${A}", "B": "${A}"},
+        },
+        {
+            name: "binary value stays literal",
+            vars: []envVar{{Name: "A", Value: "1"}, {Name: "BLOB", Value: encodeValue([]byte("${A}\x00"))}},
+            want: map[string]string{"BLOB": encodeValue([]byte("${A}\x00"))},
+        },
+        {
+            name:    "self reference",
+            vars:    []envVar{{Name: "A", Value: "x${A}"}},
+            wantErr: "reference cycle: A -> A",
+        },
+        {
+            name:    "indirect cycle",
+            vars:    []envVar{{Name: "A", Value: "${B}"}, {Name: "B", Value: "${C}"}, {Name: "C", Value: "${A}"}},
+            wantErr: "reference cycle: A -> B -> C -> A",
+        },
+        {
+            name:    "missing key",
+            vars:    []envVar{{Name: "A", Value: "${NOPE}"}},
+            wantErr: "${NOPE} is not set",
+        },
+        {
+            name:    "ref with two parts",
+            vars:    []envVar{{Name: "A", Value: "${ref:app/KEY}"}},
+            wantErr: "invalid reference ${ref:app/KEY}",
+        },
+        {
+            name:    "ref with an empty part",
+            vars:    []envVar{{Name: "A", Value: "${ref:app//KEY}"}},
+            wantErr: "invalid reference ${ref:app//KEY}",
+        },
+        {
+            name:    "ref with four parts",
+            vars:    []envVar{{Name: "A", Value: "${ref:app/dev/KEY/x}"}},
+            wantErr: "invalid reference ${ref:app/dev/KEY/x}",
+        },
+        {
+            name:    "empty ref",
+            vars:    []envVar{{Name: "A", Value: "${ref:}"}},
+            wantErr: "invalid reference ${ref:}",
+        },
+    }
+
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            cfg := &config.Config{Project: "app", Environment: "dev", Secrets: tt.schema}
+            vars, err := resolveRefs(cfg, notFoundServer(t), testMasterKey, tt.vars)
+            if tt.wantErr != "" {
+                if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+                    t.Fatalf("error = %v, want one containing %q", err, tt.wantErr)
+                }
+                return
+            }
+            if err != nil {
+                t.Fatal(err)
+            }
+
+            got := map[string]string{}
+            for _, v := range vars {
+                got[v.Name] = v.Value
+            }
+            for name, want := range tt.want {
+                if got[name] != want {
+                    t.Errorf("%s = %q, want %q", name, got[name], want)
+                }
+            }
+        })
+    }
+}
+
+// TestResolveRefsOpaque checks that references from and to opaque-key
+// projects never send a plaintext key name to the server.
+func TestResolveRefsOpaque(t *testing.T) {
+    encrypt := func(s string) string {
+        out, err := crypto.Encrypt(s, testMasterKey)
+        if err != nil {
+            t.Fatal(err)
+        }
+        return out
+    }
+    other := []client.Secret{{
+        Key:   crypto.LookupID("other", "PASSWORD", testMasterKey),
+        Name:  encrypt("PASSWORD"),
+        Value: encrypt("hunter2"),
+    }}
+
+    srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+        for _, name := range []string{"PASSWORD", "MISSING"} {
+            if strings.Contains(r.URL.RawQuery, name) {
+                t.Errorf("request sent the key name %s: %s", name, r.URL)
+            }
+        }
+        if r.URL.Query().Get("project") == "other" && !r.URL.Query().Has("key") {
+            json.NewEncoder(w).Encode(other)
+            return
+        }
+        http.NotFound(w, r)
+    }))
+    defer srv.Close()
+
+    cfg := &config.Config{Project: "app", Environment: "dev", OpaqueKeys: true}
+    cli := client.New(srv.URL, "token")
+
+    vars, err := resolveRefs(cfg, cli, testMasterKey, []envVar{{Name: "A", Value: "pw=${ref:other/dev/PASSWORD}"}})
+    if err != nil {
+        t.Fatal(err)
+    }
+    if vars[0].Value != "pw=hunter2" {
+        t.Errorf("A = %q, want %q", vars[0].Value, "pw=hunter2")
+    }
+
+    for _, ref := range []string{"${MISSING}", "${ref:app/prod/MISSING}", "${ref:other/dev/MISSING}"} {
+        _, err := resolveRefs(cfg, cli, testMasterKey, []envVar{{Name: "A", Value: ref}})
+        if err == nil || !strings.Contains(err.Error(), "is not set") {
+            t.Errorf("%s: error = %v, want not set", ref, err)
+        }
+    }
+}
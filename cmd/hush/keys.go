@@ -0,0 +1,203 @@
+package main
+
+import (
+    "fmt"
+
+    "github.com/spf13/cobra"
+    "github.com/adith2005-20/hush/pkg/client"
+    "github.com/adith2005-20/hush/pkg/config"
+    "github.com/adith2005-20/hush/pkg/crypto"
+)
+
+// serverKey returns the key the server stores name under: the name itself,
+// or an opaque lookup ID when the project encrypts key names.
+func serverKey(cfg *config.Config, masterKey []byte, name string) string {
+    if cfg.OpaqueKeys {
+        return crypto.LookupID(cfg.Project, name, masterKey)
+    }
+    return name
+}
+
+// encryptSecret builds the secret sent to the server for v.
+func encryptSecret(cfg *config.Config, masterKey []byte, v envVar) (client.Secret, error) {
+    encrypted, err := crypto.Encrypt(v.Value, masterKey)
+    if err != nil {
+        return client.Secret{}, err
+    }
+
+    secret := client.Secret{
+        Key:      serverKey(cfg, masterKey, v.Name),
+        Value:    encrypted,
+        Project:  cfg.Project,
+        Env:      cfg.Environment,
+        FileMode: uint32(v.Mode),
+    }
+    if cfg.OpaqueKeys {
+        if secret.Name, err = crypto.Encrypt(v.Name, masterKey); err != nil {
+            return client.Secret{}, err
+        }
+    }
+    if secret.File, err = encryptFileName(cfg, masterKey, v.File); err != nil {
+        return client.Secret{}, err
+    }
+    return secret, nil
+}
+
+// encryptFileName hides a file secret's name from the server in opaque-key
+// projects, as it often gives away what the secret is.
+func encryptFileName(cfg *config.Config, masterKey []byte, file string) (string, error) {
+    if !cfg.OpaqueKeys || file == "" {
+        return file, nil
+    }
+    return crypto.Encrypt(file, masterKey)
+}
+
+// secretName returns the real key name of a secret fetched from the server.
+func secretName(secret client.Secret, masterKey []byte) (string, error) {
+    if secret.Name == "" {
+        return secret.Key, nil
+    }
+    name, err := crypto.Decrypt(secret.Name, masterKey)
+    if err != nil {
+        return "", fmt.Errorf("failed to decrypt key name: %w", err)
+    }
+    return name, nil
+}
+
+// secretFile returns the file name of a file secret fetched from the
+// server, or "" for ordinary values.
+func secretFile(secret client.Secret, masterKey []byte) (string, error) {
+    if secret.File == "" || secret.Name == "" {
+        return secret.File, nil
+    }
+    file, err := crypto.Decrypt(secret.File, masterKey)
+    if err != nil {
+        return "", fmt.Errorf("failed to decrypt file name: %w", err)
+    }
+    return file, nil
+}
+
+// hasOpaqueKeys reports whether any of the secrets has an encrypted name.
+func hasOpaqueKeys(secrets []client.Secret) bool {
+    for _, s := range secrets {
+        if s.Name != "" {
+            return true
+        }
+    }
+    return false
+}
+
+var opaqueKeysCmd = &cobra.Command{
+    Use:   "opaque-keys enable|disable",
+    Short: "Encrypt key names so the server only sees lookup IDs",
+    Long: `Switch the project between plaintext and opaque key names.
+
+With opaque keys the server stores an HMAC-derived lookup ID per key and the
+name encrypted with your master key, so names like PROD_AWS_ROOT_PASSWORD
+never leave your machine in plaintext. 'hush list' and 'hush pull' show the
+real names as usual.
+
+Existing secrets in the current environment are converted in place; run the
+command once per environment.`,
+    Args:      cobra.ExactArgs(1),
+    ValidArgs: []string{"enable", "disable"},
+    RunE: func(cmd *cobra.Command, args []string) error {
+        if args[0] != "enable" && args[0] != "disable" {
+            return withCode(exitUsage, fmt.Errorf("Unknown mode %q (use enable or disable)", args[0]))
+        }
+
+        cfg, err := loadProjectConfig()
+        if err != nil {
+            return err
+        }
+
+        creds, err := config.LoadCredentials(profileFlag(), cfg)
+        if err != nil {
+            return withCode(exitAuth, err)
+        }
+
+        masterKey, err := config.LoadMasterKey()
+        if err != nil {
+            return fmt.Errorf("Error loading encryption key: %w", err)
+        }
+
+        if err := confirmProtected(cfg); err != nil {
+            return err
+        }
+        cli := client.New(creds.Server, creds.Token)
+        secrets, err := cli.GetSecrets(cfg.Project, cfg.Environment)
+        if err != nil {
+            return fmt.Errorf("Error fetching secrets: %w", err)
+        }
+
+        cfg.OpaqueKeys = args[0] == "enable"
+
+        converted := 0
+        for _, secret := range secrets {
+            // Inherited secrets are converted with their own environment.
+            if secret.InheritedFrom != "" {
+                continue
+            }
+            name, err := secretName(secret, masterKey)
+            if err != nil {
+                return withCode(exitDecrypt, fmt.Errorf("%s: %w", secret.Key, err))
+            }
+
+            newKey := serverKey(cfg, masterKey, name)
+            if newKey == secret.Key {
+                continue
+            }
+
+            file, err := secretFile(secret, masterKey)
+            if err != nil {
+                return withCode(exitDecrypt, fmt.Errorf("%s: %w", name, err))
+            }
+            meta, err := secretMetadata(secret, masterKey)
+            if err != nil {
+                return withCode(exitDecrypt, fmt.Errorf("%s: %w", name, err))
+            }
+
+            // Values are already encrypted; only the key and names change.
+            next := client.Secret{Key: newKey, Value: secret.Value, Project: cfg.Project, Env: cfg.Environment, FileMode: secret.FileMode,
+                ExpiresAt: secret.ExpiresAt, RotateEvery: secret.RotateEvery}
+            if cfg.OpaqueKeys {
+                if next.Name, err = crypto.Encrypt(name, masterKey); err != nil {
+                    return fmt.Errorf("Encryption error for %s: %w", name, err)
+                }
+            }
+            if next.File, err = encryptFileName(cfg, masterKey, file); err != nil {
+                return fmt.Errorf("Encryption error for %s: %w", name, err)
+            }
+            if err := meta.store(cfg, masterKey, &next); err != nil {
+                return fmt.Errorf("Encryption error for %s: %w", name, err)
+            }
+
+            if err := cli.PutSecret(next); err != nil {
+                return fmt.Errorf("Error converting %s: %w", name, err)
+            }
+            if err := cli.DeleteSecret(cfg.Project, cfg.Environment, secret.Key); err != nil {
+                return fmt.Errorf("Error removing old entry for %s: %w", name, err)
+            }
+            converted++
+        }
+
+        if err := config.UpdateProjectConfig(cfg, func(c *config.Config) { c.OpaqueKeys = cfg.OpaqueKeys }); err != nil {
+            return fmt.Errorf("Error saving hush.yaml: %w", err)
+        }
+
+        if cfg.OpaqueKeys {
+            success("Opaque keys enabled for %s", cfg.Project)
+        } else {
+            success("Opaque keys disabled for %s", cfg.Project)
+        }
+        success("Converted %d secrets in %s", converted, cfg.Environment)
+
+        emit(map[string]interface{}{
+            "project":     cfg.Project,
+            "environment": cfg.Environment,
+            "opaque_keys": cfg.OpaqueKeys,
+            "converted":   converted,
+        })
+        return nil
+    },
+}
@@ -1,19 +1,58 @@
 package main
 
 import (
+    "context"
+    "errors"
     "fmt"
+    "io"
     "os"
+    "os/signal"
+    "path/filepath"
     "strings"
-    
+    "syscall"
+    "time"
+
     "github.com/spf13/cobra"
     "github.com/adith2005-20/hush/pkg/config"
     "github.com/adith2005-20/hush/pkg/crypto"
     "github.com/adith2005-20/hush/pkg/client"
 )
 
+// started is set once flags and arguments have been accepted, so errors
+// before that point exit with exitUsage.
+var started bool
+
 var rootCmd = &cobra.Command{
     Use:   "hush",
     Short: "Hush - Secrets manager for developers",
+    Long: `Hush - Secrets manager for developers
+
+Results go to stdout and diagnostics to stderr. With --output json, every
+command prints a JSON result instead of prose, and errors are printed to
+stderr as {"error": ..., "exit_code": ...}.
+
+Exit codes:
+  0  success
+  1  other failure
+  2  invalid flags or arguments
+  3  authentication failed or not logged in
+  4  secret, profile or hush.yaml not found
+  5  conflict with existing state
+  6  server unreachable
+  7  decryption failed (wrong master key?)
+  8  hush scan found secrets`,
+    SilenceErrors: true,
+    SilenceUsage:  true,
+    PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+        if format, _ := cmd.Flags().GetString("output"); format != "text" && format != "json" {
+            return fmt.Errorf("unknown --output %q (use text or json)", format)
+        }
+        if group, _ := cmd.Flags().GetString("group"); group != "" && !groupCommands[cmd.Name()] {
+            return fmt.Errorf("--group does not apply to 'hush %s'", cmd.Name())
+        }
+        started = true
+        return nil
+    },
 }
 
 var loginCmd = &cobra.Command{
@@ -21,43 +60,57 @@ var loginCmd = &cobra.Command{
     Short: "Login to a Hush server",
     Long: `Authenticate with a Hush server and save credentials.
 
+Credentials are stored as a named profile ("default" unless --profile or
+HUSH_PROFILE is set) and the profile becomes the current one.
+
 Examples:
   hush login http://localhost:55555 hush_abc123
-  hush login https://secrets.mycompany.com hush_def456`,
+  hush login https://secrets.mycompany.com hush_def456 --profile work`,
     Args: cobra.ExactArgs(2),
-    Run: func(cmd *cobra.Command, args []string) {
+    RunE: func(cmd *cobra.Command, args []string) error {
         server := args[0]
         token := args[1]
-        
-        fmt.Printf("🔐 Connecting to %s...\n", server)
-        
+
+        info("🔐 Connecting to %s...", server)
+
         // Test connection
         cli := client.New(server, token)
         if err := cli.Ping(); err != nil {
-            fmt.Printf("❌ Failed to connect: %v\n", err)
-            os.Exit(1)
+            return withCode(exitNetwork, fmt.Errorf("Failed to connect: %w", err))
+        }
+
+        profile := profileFlag()
+        if profile == "" {
+            profile = os.Getenv("HUSH_PROFILE")
+        }
+        if profile == "" {
+            profile = config.DefaultProfile
         }
-        
+
         // Save credentials
-        if err := config.SaveCredentials(server, token); err != nil {
-            fmt.Printf("❌ Failed to save credentials: %v\n", err)
-            os.Exit(1)
+        if err := config.SaveCredentials(profile, server, token); err != nil {
+            return fmt.Errorf("Failed to save credentials: %w", err)
         }
-        
+
         // Generate master key if doesn't exist
+        generated := false
         if _, err := config.LoadMasterKey(); err != nil {
             salt, _ := crypto.GenerateSalt()
             if err := config.SaveMasterKey(salt); err != nil {
-                fmt.Printf("❌ Failed to create master key: %v\n", err)
-                os.Exit(1)
+                return fmt.Errorf("Failed to create master key: %w", err)
             }
-            fmt.Println("✓ Generated master encryption key")
+            generated = true
+            success("Generated master encryption key")
         }
-        
-        fmt.Println("✓ Authenticated successfully!")
-        fmt.Println()
-        fmt.Println("Next steps:")
-        fmt.Println("  hush init myproject    # Initialize a project")
+
+        success("Authenticated successfully!")
+        success("Saved as profile %q (now current)", profile)
+        info("")
+        info("Next steps:")
+        info("  hush init myproject    # Initialize a project")
+
+        emit(map[string]interface{}{"profile": profile, "server": server, "generated_master_key": generated})
+        return nil
     },
 }
 
@@ -65,195 +118,993 @@ var initCmd = &cobra.Command{
     Use:   "init [project-name]",
     Short: "Initialize a new Hush project",
     Args:  cobra.ExactArgs(1),
-    Run: func(cmd *cobra.Command, args []string) {
+    RunE: func(cmd *cobra.Command, args []string) error {
         // Check if logged in
-        creds, err := config.LoadCredentials()
+        creds, err := config.LoadCredentials(profileFlag(), nil)
         if err != nil {
-            fmt.Println("❌ Not logged in!")
-            fmt.Println("\nRun this first:")
-            fmt.Println("  hush login http://server:55555 your-token")
-            os.Exit(1)
+            return withCode(exitAuth, fmt.Errorf("%w\n\nRun this first:\n  hush login http://server:55555 your-token", err))
         }
-        
-        env, _ := cmd.Flags().GetString("env")
+
+        env := envFlag()
+        if env == "" {
+            env = config.DefaultEnvironment
+        }
+        opaque, _ := cmd.Flags().GetBool("opaque-keys")
 
         cfg := &config.Config{
             Project:     args[0],
             Server:      creds.Server,
             Environment: env,
+            OpaqueKeys:  opaque,
             Output: config.OutputConfig{
                 Format: "dotenv",
                 Path:   ".env",
             },
         }
 
+        // Only pin a profile name the user chose explicitly; otherwise the
+        // server URL selects whichever profile is logged in to it.
+        if profileFlag() != "" || os.Getenv("HUSH_PROFILE") != "" {
+            cfg.Profile = creds.Profile
+        }
+
         if err := cfg.Save(); err != nil {
-            fmt.Printf("❌ Error: %v\n", err)
-            os.Exit(1)
-        }
-
-        fmt.Printf("✓ Initialized project: %s\n", args[0])
-        fmt.Printf("✓ Created hush.yaml\n")
-        fmt.Printf("✓ Environment: %s\n", env)
-        fmt.Println()
-        fmt.Println("Next steps:")
-        fmt.Println("  hush set KEY=value    # Add secrets")
-        fmt.Println("  hush list             # View secrets")
-        fmt.Println("  hush pull             # Download to .env")
+            return fmt.Errorf("Error: %w", err)
+        }
+
+        success("Initialized project: %s", args[0])
+        success("Created hush.yaml")
+        success("Environment: %s", env)
+        success("Server: %s (profile %s)", creds.Server, creds.Profile)
+        if opaque {
+            success("Key names will be encrypted")
+        }
+        info("")
+        info("Next steps:")
+        info("  hush set KEY=value    # Add secrets")
+        info("  hush list             # View secrets")
+        info("  hush pull             # Download to .env")
+
+        emit(map[string]interface{}{
+            "project":     args[0],
+            "environment": env,
+            "server":      creds.Server,
+            "profile":     creds.Profile,
+            "opaque_keys": opaque,
+        })
+        return nil
     },
 }
 
+// keyResult is the JSON result of commands that change several keys.
+type keyResult struct {
+    Project     string      `json:"project"`
+    Environment string      `json:"environment"`
+    Changed     []string    `json:"changed"`
+    Failed      []keyFailed `json:"failed,omitempty"`
+}
+
+type keyFailed struct {
+    Key   string `json:"key"`
+    Error string `json:"error"`
+}
+
+// finish emits the result and returns the first failure, so the exit code
+// reflects it, or nil if every key succeeded.
+func (r *keyResult) finish(errs []error) error {
+    if r.Changed == nil {
+        r.Changed = []string{}
+    }
+    emit(r)
+    if len(errs) == 0 {
+        return nil
+    }
+    // Each failure has already been reported as a warning.
+    return withCode(exitCode(errs[0]), fmt.Errorf("%d of %d keys failed", len(errs), len(errs)+len(r.Changed)))
+}
+
 var setCmd = &cobra.Command{
-    Use:   "set KEY=VALUE [KEY2=VALUE2 ...]",
+    Use:   "set KEY[=VALUE] [KEY2[=VALUE2] ...]",
     Short: "Set one or more secrets",
+    Long: `Set one or more secrets. Every key is attempted; the command exits
+non-zero if any of them failed.
+
+Values given as KEY=VALUE end up in shell history and process listings. A
+bare KEY takes its value from elsewhere instead:
+
+  hush set API_KEY                        # prompt without echo, twice
+  pbpaste | hush set API_KEY --stdin      # a single trailing newline is dropped
+  hush set TLS_CERT --from-file cert.pem  # the file's exact contents
+  hush set DB_PASSWORD --generate --length 40 --charset symbols
+
+Files that are not UTF-8 text are stored base64-encoded, and pull writes
+them back out as files under output.files (see hush.yaml).
+
+--as-file stores a file secret, such as a kubeconfig or service account
+JSON. pull writes it to output.files under its file name (the --from-file
+name unless --file-name is given) with --mode permissions, and sets KEY to
+its path in the output file:
+
+  hush set GOOGLE_APPLICATION_CREDENTIALS --from-file sa.json --as-file
+
+--expires and --rotate-every record when a value is due for replacement,
+shown by 'hush list' and 'hush status'. Replacing a value keeps its expiry
+unless new flags are given, and a bare KEY with only expiry flags changes
+the expiry of the current value:
+
+  hush set STRIPE_KEY --stdin --expires 2026-12-31
+  hush set DB_PASSWORD --rotate-every 90d
+
+--desc, --owner, --tag and --link record what a secret is for and who looks
+after it. They are kept the same way, and shown by 'hush list --output json';
+'hush list --tag' filters on tags:
+
+  hush set STRIPE_KEY --desc "Live API key" --owner payments-team \
+      --tag payments --tag vendor --link https://dashboard.stripe.com/apikeys`,
     Args:  cobra.MinimumNArgs(1),
-    Run: func(cmd *cobra.Command, args []string) {
-        cfg, err := config.LoadProjectConfig()
+    RunE: func(cmd *cobra.Command, args []string) error {
+        fromStdin, _ := cmd.Flags().GetBool("stdin")
+        fromFile, _ := cmd.Flags().GetString("from-file")
+        asFile, _ := cmd.Flags().GetBool("as-file")
+        generate, _ := cmd.Flags().GetBool("generate")
+        length, _ := cmd.Flags().GetInt("length")
+        charset, _ := cmd.Flags().GetString("charset")
+
+        sources := 0
+        for _, set := range []bool{fromStdin, fromFile != "", generate} {
+            if set {
+                sources++
+            }
+        }
+        if sources > 1 {
+            return withCode(exitUsage, errors.New("use only one of --stdin, --from-file and --generate"))
+        }
+        if (fromStdin || fromFile != "") && (len(args) != 1 || strings.Contains(args[0], "=")) {
+            return withCode(exitUsage, errors.New("--stdin and --from-file set a single KEY"))
+        }
+        if !generate && (cmd.Flags().Changed("length") || cmd.Flags().Changed("charset")) {
+            return withCode(exitUsage, errors.New("--length and --charset need --generate"))
+        }
+        if asFile && !fromStdin && fromFile == "" {
+            return withCode(exitUsage, errors.New("--as-file needs --from-file or --stdin"))
+        }
+        file, mode, err := fileFlags(cmd, asFile, fromFile)
         if err != nil {
-            fmt.Printf("❌ %v\n", err)
-            os.Exit(1)
+            return err
+        }
+        exp, err := expiryFlags(cmd)
+        if err != nil {
+            return err
+        }
+        meta, err := metadataFlags(cmd)
+        if err != nil {
+            return err
         }
 
-        creds, err := config.LoadCredentials()
+        cfg, err := loadProjectConfig()
         if err != nil {
-            fmt.Printf("❌ %v\n", err)
-            os.Exit(1)
+            return err
+        }
+
+        creds, err := config.LoadCredentials(profileFlag(), cfg)
+        if err != nil {
+            return withCode(exitAuth, err)
         }
 
         masterKey, err := config.LoadMasterKey()
         if err != nil {
-            fmt.Printf("❌ Error loading encryption key: %v\n", err)
-            os.Exit(1)
+            return fmt.Errorf("Error loading encryption key: %w", err)
         }
 
+        if err := confirmProtected(cfg); err != nil {
+            return err
+        }
         cli := client.New(creds.Server, creds.Token)
 
+        result := &keyResult{Project: cfg.Project, Environment: cfg.Environment}
+        var errs []error
+        fail := func(key string, err error) {
+            warn("%v", err)
+            result.Failed = append(result.Failed, keyFailed{Key: key, Error: err.Error()})
+            errs = append(errs, err)
+        }
+
         for _, arg := range args {
-            parts := strings.SplitN(arg, "=", 2)
-            if len(parts) != 2 {
-                fmt.Printf("⚠️  Invalid format: %s (use KEY=VALUE)\n", arg)
+            key, value, hasValue := strings.Cut(arg, "=")
+            if key == "" {
+                fail(arg, withCode(exitUsage, fmt.Errorf("Invalid format: %s (use KEY=VALUE)", arg)))
                 continue
             }
-
-            key, value := parts[0], parts[1]
-            encrypted, err := crypto.Encrypt(value, masterKey)
-            if err != nil {
-                fmt.Printf("❌ Encryption error for %s: %v\n", key, err)
+            v := envVar{Name: key, Value: value, File: file, Mode: mode, Expiry: exp, Meta: meta}
+            if !hasValue {
+                var err error
+                switch {
+                case fromStdin:
+                    v.Value, err = readStdin()
+                case fromFile != "":
+                    v.Value, err = readValueFile(fromFile)
+                case generate:
+                    v.Value, err = randomString(length, charset)
+                case exp != nil || meta != nil:
+                    // Only the expiry or metadata change.
+                    v, err = currentValue(cfg, cli, masterKey, key)
+                    v.Expiry, v.Meta = exp, meta
+                default:
+                    v.Value, err = promptValue(key)
+                }
+                if err != nil {
+                    fail(key, withCode(exitCode(err), fmt.Errorf("Error reading %s: %w", key, err)))
+                    continue
+                }
+            } else if sources > 0 {
+                fail(key, withCode(exitUsage, fmt.Errorf("%s has a value; --stdin, --from-file and --generate apply to bare keys", key)))
                 continue
             }
 
-            if err := cli.SetSecret(cfg.Project, cfg.Environment, key, encrypted); err != nil {
-                fmt.Printf("❌ Error setting %s: %v\n", key, err)
+            if err := putSecret(cfg, cli, masterKey, v); err != nil {
+                fail(key, err)
                 continue
             }
 
-            fmt.Printf("✓ Set %s\n", key)
+            success("Set %s", key)
+            result.Changed = append(result.Changed, key)
         }
+
+        return result.finish(errs)
     },
 }
 
+// putSecret checks v against the schema in hush.yaml, encrypts it and
+// stores it.
+func putSecret(cfg *config.Config, cli *client.Client, masterKey []byte, v envVar) error {
+    // Values with references are checked once resolved, on pull.
+    if spec := cfg.Secrets.Lookup(v.Name); spec != nil && !strings.Contains(v.Value, "${") {
+        if err := spec.Validate(v.Value); err != nil {
+            return fmt.Errorf("Rejected %s: %v (see secrets: in hush.yaml)", v.Name, err)
+        }
+    }
+
+    secret, err := encryptSecret(cfg, masterKey, v)
+    if err != nil {
+        return fmt.Errorf("Encryption error for %s: %w", v.Name, err)
+    }
+    // Replacing a value keeps its expiry and metadata unless new ones are
+    // given; a rotation interval counts from the new value.
+    old, err := cli.GetSecret(secret.Project, secret.Env, secret.Key)
+    switch {
+    case errors.Is(err, client.ErrNotFound):
+        old = &client.Secret{}
+    case err != nil:
+        return fmt.Errorf("Error reading %s before setting it: %w", v.Name, err)
+    case old.InheritedFrom != "":
+        old = &client.Secret{}
+    }
+    if v.Expiry != nil {
+        v.Expiry.apply(&secret)
+    } else {
+        secret.ExpiresAt, secret.RotateEvery = old.ExpiresAt, old.RotateEvery
+    }
+    meta, err := secretMetadata(*old, masterKey)
+    if err != nil {
+        return withCode(exitDecrypt, fmt.Errorf("%s: %w", v.Name, err))
+    }
+    if v.Meta != nil {
+        v.Meta.apply(&meta)
+    }
+    if err := meta.store(cfg, masterKey, &secret); err != nil {
+        return fmt.Errorf("Encryption error for %s: %w", v.Name, err)
+    }
+
+    if err := cli.PutSecret(secret); err != nil {
+        return fmt.Errorf("Error setting %s: %w", v.Name, err)
+    }
+    return nil
+}
+
+// pullResult is the JSON result of pulling one project environment.
+type pullResult struct {
+    Project     string   `json:"project"`
+    Environment string   `json:"environment"`
+    Path        string   `json:"path"`
+    Count       int      `json:"count"`
+    Templates   []string `json:"templates,omitempty"`
+    Error       string   `json:"error,omitempty"`
+}
+
 var pullCmd = &cobra.Command{
     Use:   "pull",
     Short: "Pull secrets and write to output file",
-    Run: func(cmd *cobra.Command, args []string) {
-        cfg, err := config.LoadProjectConfig()
+    Long: `Pull secrets and write them to the project's output file.
+
+hush.yaml is looked up in the current directory and its parents, up to the
+root of the git repository. In a monorepo whose root hush.yaml declares
+projects:, --all pulls every one of them into its own output file.
+
+--env all pulls every environment declared under environments: in hush.yaml,
+each to its own output path. Protected environments ask for confirmation.`,
+    RunE: func(cmd *cobra.Command, args []string) error {
+        all, _ := cmd.Flags().GetBool("all")
+        raw, _ := cmd.Flags().GetBool("raw")
+        watch, _ := cmd.Flags().GetBool("watch")
+
+        var configs []*config.Config
+        var err error
+        if all {
+            configs, err = config.LoadAllProjectConfigs(envFlag())
+        } else {
+            configs, err = config.LoadProjectConfigs(envFlag())
+        }
         if err != nil {
-            fmt.Printf("❌ %v\n", err)
-            os.Exit(1)
+            return err
         }
 
-        creds, err := config.LoadCredentials()
+        if len(configs) > 1 {
+            if watch {
+                return withCode(exitUsage, errors.New("--watch needs a single project and environment"))
+            }
+            return pullAll(configs, raw)
+        }
+
+        cfg := configs[0]
+        if err := confirmProtected(cfg); err != nil {
+            return err
+        }
+
+        creds, err := config.LoadCredentials(profileFlag(), cfg)
         if err != nil {
-            fmt.Printf("❌ %v\n", err)
-            os.Exit(1)
+            return withCode(exitAuth, err)
         }
 
         masterKey, err := config.LoadMasterKey()
         if err != nil {
-            fmt.Printf("❌ Error loading encryption key: %v\n", err)
-            os.Exit(1)
+            return fmt.Errorf("Error loading encryption key: %w", err)
         }
 
         cli := client.New(creds.Server, creds.Token)
-        secrets, err := cli.GetSecrets(cfg.Project, cfg.Environment)
+        n, err := pullSecrets(cfg, cli, masterKey, raw)
         if err != nil {
-            fmt.Printf("❌ Error fetching secrets: %v\n", err)
-            os.Exit(1)
+            return err
         }
 
-        if len(secrets) == 0 {
-            fmt.Println("⚠️  No secrets found")
-            fmt.Println("\nAdd secrets with:")
-            fmt.Println("  hush set KEY=value")
-            return
+        success("Pulled %d secrets to %s", n, cfg.OutputPath())
+        if n == 0 {
+            warn("No secrets found")
+            info("\nAdd secrets with:")
+            info("  hush set KEY=value")
         }
+        result := pullResult{Project: cfg.Project, Environment: cfg.Environment, Path: cfg.OutputPath(), Count: n}
+        for _, t := range cfg.Templates {
+            success("Rendered %s", cfg.ProjectPath(t.Path))
+            result.Templates = append(result.Templates, cfg.ProjectPath(t.Path))
+        }
+        emit(result)
 
-        var output strings.Builder
-        for _, secret := range secrets {
-            decrypted, err := crypto.Decrypt(secret.Value, masterKey)
-            if err != nil {
-                fmt.Printf("❌ Error decrypting %s: %v\n", secret.Key, err)
+        if watch {
+            watchSecrets(cfg, cli, masterKey, raw)
+        }
+        return nil
+    },
+}
+
+// pullAll pulls several projects or environments, carrying on past
+// failures.
+func pullAll(configs []*config.Config, raw bool) error {
+    written := map[string]string{}
+    for _, cfg := range configs {
+        target := cfg.Project + "/" + cfg.Environment
+        path := filepath.Clean(cfg.OutputPath())
+        if other, ok := written[path]; ok {
+            return withCode(exitConflict, fmt.Errorf("%s and %s would both write %s; give each environment its own output.path", other, target, path))
+        }
+        written[path] = target
+    }
+    for _, cfg := range configs {
+        target := cfg.Project + "/" + cfg.Environment
+        for _, t := range cfg.Templates {
+            path := filepath.Clean(cfg.ProjectPath(t.Path))
+            if other, ok := written[path]; ok {
+                return withCode(exitConflict, fmt.Errorf("%s and %s would both write %s; declare templates: under each environment with its own path", other, target, path))
+            }
+            written[path] = target
+        }
+    }
+    for _, cfg := range configs {
+        if err := confirmProtected(cfg); err != nil {
+            return err
+        }
+    }
+
+    masterKey, err := config.LoadMasterKey()
+    if err != nil {
+        return fmt.Errorf("Error loading encryption key: %w", err)
+    }
+
+    // Every environment is fetched before any is written, so file secrets
+    // that two of them would write to the same place are caught first.
+    results := make([]pullResult, len(configs))
+    pending := make([][]envVar, len(configs))
+    files := map[string]string{}
+    var firstErr error
+    for i, cfg := range configs {
+        target := cfg.Project + "/" + cfg.Environment
+        results[i] = pullResult{Project: cfg.Project, Environment: cfg.Environment, Path: cfg.OutputPath()}
+
+        creds, err := config.LoadCredentials(profileFlag(), cfg)
+        if err == nil {
+            pending[i], err = prepareSecrets(cfg, client.New(creds.Server, creds.Token), masterKey, raw)
+        } else {
+            err = withCode(exitAuth, err)
+        }
+        if err != nil {
+            warn("%s/%s: %v", cfg.Project, cfg.Environment, err)
+            results[i].Error = err.Error()
+            if firstErr == nil {
+                firstErr = err
+            }
+            continue
+        }
+
+        for _, v := range pending[i] {
+            name, ok := secretFileName(v)
+            if !ok {
                 continue
             }
-            output.WriteString(fmt.Sprintf("%s%s=%s\n", cfg.Prefix, secret.Key, decrypted))
+            path := filepath.Join(filepath.Clean(cfg.FilesPath()), name)
+            if other, ok := files[path]; ok && other != target {
+                return withCode(exitConflict, fmt.Errorf("%s and %s would both write %s; give each environment its own output.files", other, target, path))
+            }
+            files[path] = target
+        }
+    }
+
+    for i, cfg := range configs {
+        if results[i].Error != "" {
+            continue
+        }
+        n, err := writeSecrets(cfg, pending[i])
+        switch {
+        case err != nil:
+            warn("%s/%s: %v", cfg.Project, cfg.Environment, err)
+            results[i].Error = err.Error()
+            if firstErr == nil {
+                firstErr = err
+            }
+        case n == 0:
+            warn("%s/%s: no secrets found", cfg.Project, cfg.Environment)
+        default:
+            success("%s/%s: pulled %d secrets to %s", cfg.Project, cfg.Environment, n, cfg.OutputPath())
         }
+        results[i].Count = n
+    }
+
+    emit(results)
+    if firstErr != nil {
+        return withCode(exitCode(firstErr), errors.New("some projects could not be pulled"))
+    }
+    return nil
+}
+
+// pullSecrets fetches and decrypts the environment, resolves references
+// between secrets unless raw is set, checks it against the schema in
+// hush.yaml and atomically rewrites the output file and any templates. It returns
+// the number of secrets written.
+func pullSecrets(cfg *config.Config, cli *client.Client, masterKey []byte, raw bool) (int, error) {
+    vars, err := prepareSecrets(cfg, cli, masterKey, raw)
+    if err != nil {
+        return 0, err
+    }
+    return writeSecrets(cfg, vars)
+}
 
-        if err := os.WriteFile(cfg.Output.Path, []byte(output.String()), 0600); err != nil {
-            fmt.Printf("❌ Error writing to %s: %v\n", cfg.Output.Path, err)
-            os.Exit(1)
+// prepareSecrets is the part of pullSecrets that reads: it returns the
+// secrets to write, resolved and checked.
+func prepareSecrets(cfg *config.Config, cli *client.Client, masterKey []byte, raw bool) ([]envVar, error) {
+    vars, err := fetchSecrets(cfg, cli, masterKey)
+    if err != nil {
+        return nil, err
+    }
+    if !raw {
+        if vars, err = resolveRefs(cfg, cli, masterKey, vars); err != nil {
+            return nil, err
         }
+    }
+    return applySchema(cfg, vars)
+}
 
-        fmt.Printf("✓ Pulled %d secrets to %s\n", len(secrets), cfg.Output.Path)
-    },
+// writeSecrets is the part of pullSecrets that writes the output file, file
+// secrets and templates. An empty environment still rewrites them, so
+// unsetting the last secret doesn't leave its value behind.
+func writeSecrets(cfg *config.Config, vars []envVar) (int, error) {
+    // Templates see the values themselves, unprefixed, rather than what
+    // the output file holds.
+    values := append([]envVar(nil), vars...)
+
+    vars, err := writeFiles(cfg.FilesPath(), vars, cfg.FileRefs())
+    if err != nil {
+        return 0, err
+    }
+    for i := range vars {
+        vars[i].Name = cfg.Prefix + vars[i].Name
+    }
+    output, err := formatSecrets(cfg.Output.Format, vars)
+    if err != nil {
+        return 0, err
+    }
+
+    if err := writeFileAtomic(cfg.OutputPath(), output, 0600); err != nil {
+        return 0, fmt.Errorf("Error writing to %s: %w", cfg.OutputPath(), err)
+    }
+
+    if err := renderTemplates(cfg, values); err != nil {
+        return 0, err
+    }
+    return len(values), nil
+}
+
+// applySchema checks vars against the schema in hush.yaml and adds the
+// defaults of keys that are not set.
+func applySchema(cfg *config.Config, vars []envVar) ([]envVar, error) {
+    values := make(map[string]string, len(vars))
+    for _, v := range vars {
+        values[v.Name] = v.Value
+    }
+    if violations := cfg.Secrets.Check(values); len(violations) > 0 {
+        return nil, schemaError(violations)
+    }
+    // Check fills in defaults for keys that are not set.
+    for _, spec := range cfg.Secrets {
+        if _, ok := values[spec.Name]; ok && !hasVar(vars, spec.Name) {
+            vars = append(vars, envVar{Name: spec.Name, Value: values[spec.Name]})
+        }
+    }
+    return vars, nil
+}
+
+// fetchSecrets downloads and decrypts the environment, merged with the
+// groups it includes (see mergeGroups).
+func fetchSecrets(cfg *config.Config, cli *client.Client, masterKey []byte) ([]envVar, error) {
+    vars, err := fetchEnvironment(cfg, cli, masterKey)
+    if err != nil {
+        return nil, err
+    }
+    return mergeGroups(cfg, cli, masterKey, vars)
+}
+
+// fetchEnvironment downloads and decrypts cfg's own secrets. It fails if
+// any secret cannot be decrypted, after reporting each one.
+func fetchEnvironment(cfg *config.Config, cli *client.Client, masterKey []byte) ([]envVar, error) {
+    secrets, err := cli.GetSecrets(cfg.Project, cfg.Environment)
+    if err != nil {
+        return nil, fmt.Errorf("Error fetching secrets: %w", err)
+    }
+
+    var vars []envVar
+    failed := 0
+    for _, secret := range secrets {
+        name, err := secretName(secret, masterKey)
+        if err != nil {
+            warn("Error decrypting %s: %v", secret.Key, err)
+            failed++
+            continue
+        }
+        decrypted, err := crypto.Decrypt(secret.Value, masterKey)
+        if err != nil {
+            warn("Error decrypting %s: %v", name, err)
+            failed++
+            continue
+        }
+        file, err := secretFile(secret, masterKey)
+        if err != nil {
+            warn("Error decrypting %s: %v", name, err)
+            failed++
+            continue
+        }
+        vars = append(vars, envVar{Name: name, Value: decrypted, File: file, Mode: os.FileMode(secret.FileMode)})
+    }
+
+    if failed > 0 {
+        return nil, withCode(exitDecrypt, fmt.Errorf("%d of %d secrets could not be decrypted; is this the right master key?", failed, len(secrets)))
+    }
+    return vars, nil
+}
+
+func hasVar(vars []envVar, name string) bool {
+    for _, v := range vars {
+        if v.Name == name {
+            return true
+        }
+    }
+    return false
+}
+
+// watchSecrets re-pulls whenever the server reports a change to the
+// project or a group it includes, until interrupted.
+func watchSecrets(cfg *config.Config, cli *client.Client, masterKey []byte, raw bool) {
+    ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+    defer stop()
+
+    // pull has already checked include:.
+    groups, _ := cfg.IncludedGroups()
+    events := make(chan client.Event)
+    go watchStream(ctx, cli, cfg.Project, cfg.Environment, events)
+    for _, group := range groups {
+        go watchStream(ctx, cli, group.Project, group.Environment, events)
+    }
+
+    info("👀 Watching %s/%s for changes (Ctrl+C to stop)", cfg.Project, cfg.Environment)
+
+    for {
+        var e client.Event
+        select {
+        case <-ctx.Done():
+            return
+        case e = <-events:
+        }
+
+        n, err := pullSecrets(cfg, cli, masterKey, raw)
+        if err != nil {
+            warn("%v", err)
+            continue
+        }
+        if e.Action == client.ActionReady {
+            continue
+        }
+        key, by := e.Key, ""
+        if name, ok := strings.CutPrefix(e.Project, config.GroupPrefix); ok {
+            key += " in group " + name
+        } else if cfg.OpaqueKeys {
+            key = "secret"
+        }
+        if e.Environment != cfg.Environment {
+            key += " in " + e.Environment
+        }
+        if e.Actor != "" {
+            by = " by " + e.Actor
+        }
+        success("%s %s%s, pulled %d secrets to %s", key, e.Action, by, n, cfg.OutputPath())
+        emit(pullResult{Project: cfg.Project, Environment: cfg.Environment, Path: cfg.OutputPath(), Count: n})
+    }
+}
+
+// watchStream sends the change events of one project environment to events,
+// reconnecting with the last seen revision until ctx is done.
+func watchStream(ctx context.Context, cli *client.Client, project, env string, events chan<- client.Event) {
+    var last int64
+    backoff := time.Second
+    for {
+        err := cli.Watch(ctx, project, env, last, func(e client.Event) error {
+            backoff = time.Second
+            // A reconnect replays everything after the last revision. Without
+            // one, the server says when it is subscribed instead, and the
+            // secrets are pulled again to catch changes made before that.
+            if e.Action != client.ActionReady {
+                last = e.Revision
+            }
+            select {
+            case events <- e:
+                return nil
+            case <-ctx.Done():
+                return ctx.Err()
+            }
+        })
+        if ctx.Err() != nil {
+            return
+        }
+        if err != nil && err != io.ErrUnexpectedEOF {
+            warn("Watch interrupted: %v (retrying in %s)", err, backoff)
+        }
+
+        select {
+        case <-ctx.Done():
+            return
+        case <-time.After(backoff):
+        }
+        if backoff < time.Minute {
+            backoff *= 2
+        }
+    }
+}
+
+// writeFileAtomic writes data to a temporary file beside path and renames it
+// into place, so readers never observe a partially written file.
+func writeFileAtomic(path string, data []byte, perm os.FileMode) error {
+    tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".tmp*")
+    if err != nil {
+        return err
+    }
+    defer os.Remove(tmp.Name())
+
+    if _, err := tmp.Write(data); err != nil {
+        tmp.Close()
+        return err
+    }
+    if err := tmp.Chmod(perm); err != nil {
+        tmp.Close()
+        return err
+    }
+    if err := tmp.Close(); err != nil {
+        return err
+    }
+    return os.Rename(tmp.Name(), path)
+}
+
+// listEntry is one key in the JSON result of hush list.
+type listEntry struct {
+    Key       string `json:"key"`
+    UpdatedAt string `json:"updated_at,omitempty"`
+    // Group is the included group the key comes from, if any.
+    Group     string `json:"group,omitempty"`
+    // InheritedFrom is the parent environment the key comes from, if any.
+    InheritedFrom string `json:"inherited_from,omitempty"`
+    // DueAt and Expiry are set for keys with an expiry or rotation interval.
+    DueAt     string `json:"due_at,omitempty"`
+    Expiry    string `json:"expiry,omitempty"`
+    secretMeta
+    Error     string `json:"error,omitempty"`
 }
 
 var listCmd = &cobra.Command{
     Use:   "list",
     Short: "List all secrets (keys only)",
-    Run: func(cmd *cobra.Command, args []string) {
-        cfg, err := config.LoadProjectConfig()
+    Long: `List the keys in the environment, with keys from included groups and
+parent environments marked as such. Values are never shown.
+
+--tag lists only secrets carrying every given tag (see 'hush set --tag'), and
+--output json includes each secret's description, owner, tags and link.`,
+    RunE: func(cmd *cobra.Command, args []string) error {
+        tags, err := cmd.Flags().GetStringSlice("tag")
+        if err != nil {
+            return withCode(exitUsage, err)
+        }
+        if tags, err = parseTags(tags); err != nil {
+            return err
+        }
+
+        cfg, err := loadProjectConfig()
         if err != nil {
-            fmt.Printf("❌ %v\n", err)
-            os.Exit(1)
+            return err
         }
 
-        creds, err := config.LoadCredentials()
+        creds, err := config.LoadCredentials(profileFlag(), cfg)
         if err != nil {
-            fmt.Printf("❌ %v\n", err)
-            os.Exit(1)
+            return withCode(exitAuth, err)
         }
 
         cli := client.New(creds.Server, creds.Token)
         secrets, err := cli.GetSecrets(cfg.Project, cfg.Environment)
         if err != nil {
-            fmt.Printf("❌ Error: %v\n", err)
-            os.Exit(1)
+            return fmt.Errorf("Error: %w", err)
         }
 
-        if len(secrets) == 0 {
-            fmt.Println("No secrets found")
-            return
+        var masterKey []byte
+        if hasOpaqueKeys(secrets) {
+            if masterKey, err = config.LoadMasterKey(); err != nil {
+                return fmt.Errorf("Error loading encryption key: %w", err)
+            }
         }
 
-        fmt.Printf("Secrets for %s/%s:\n", cfg.Project, cfg.Environment)
+        now := time.Now()
+        entries := []listEntry{}
+        dues := map[string]time.Time{}
         for _, secret := range secrets {
-            fmt.Printf("  • %s\n", secret.Key)
+            entry := listEntry{Key: secret.Key, UpdatedAt: secret.UpdatedAt, InheritedFrom: secret.InheritedFrom, DueAt: secret.DueAt}
+            state, due := dueState(secret.DueAt, now, defaultExpiryWarning)
+            entry.Expiry = state
+            if name, err := secretName(secret, masterKey); err != nil {
+                entry.Error = err.Error()
+            } else {
+                entry.Key = name
+            }
+            if meta, err := secretMetadata(secret, masterKey); err != nil {
+                entry.Error = err.Error()
+            } else {
+                entry.secretMeta = meta
+            }
+            dues[entry.Key] = due
+            entries = append(entries, entry)
+        }
+
+        // Keys from included groups are listed after the project's own,
+        // except those it overrides.
+        groups, err := cfg.IncludedGroups()
+        if err != nil {
+            return withCode(exitUsage, err)
+        }
+        listed := map[string]bool{}
+        for _, entry := range entries {
+            listed[entry.Key] = true
         }
+        for _, group := range groups {
+            secrets, err := cli.GetSecrets(group.Project, group.Environment)
+            if err != nil {
+                return fmt.Errorf("Error listing group %s: %w", group.GroupName(), err)
+            }
+            for _, secret := range secrets {
+                if !listed[secret.Key] {
+                    listed[secret.Key] = true
+                    state, due := dueState(secret.DueAt, now, defaultExpiryWarning)
+                    dues[secret.Key] = due
+                    meta, _ := secretMetadata(secret, nil)
+                    entries = append(entries, listEntry{Key: secret.Key, UpdatedAt: secret.UpdatedAt, Group: group.GroupName(), DueAt: secret.DueAt, Expiry: state, secretMeta: meta})
+                }
+            }
+        }
+
+        if len(tags) > 0 {
+            tagged := []listEntry{}
+            for _, entry := range entries {
+                if entry.hasTags(tags) {
+                    tagged = append(tagged, entry)
+                }
+            }
+            entries = tagged
+        }
+
+        if jsonOutput() {
+            emit(entries)
+            return nil
+        }
+
+        if len(entries) == 0 && len(tags) > 0 {
+            info("No secrets tagged %s", strings.Join(tags, ", "))
+            return nil
+        }
+        if len(entries) == 0 {
+            info("No secrets found")
+            return nil
+        }
+
+        // Quiet mode prints bare names, one per line.
+        info("Secrets for %s/%s:", cfg.Project, cfg.Environment)
+        for _, entry := range entries {
+            if quiet() {
+                fmt.Println(entry.Key)
+                continue
+            }
+            var notes []string
+            switch {
+            case entry.Error != "":
+                notes = append(notes, entry.Error)
+            case entry.Group != "":
+                notes = append(notes, "from "+entry.Group)
+            case entry.InheritedFrom != "":
+                notes = append(notes, "inherited from "+entry.InheritedFrom)
+            }
+            mark := "•"
+            if entry.Expiry == stateExpiring || entry.Expiry == stateExpired {
+                mark = map[string]string{stateExpiring: "⚠️ ", stateExpired: "❌"}[entry.Expiry]
+                notes = append(notes, describeDue(dues[entry.Key], now))
+            }
+            line := fmt.Sprintf("  %s %s", mark, entry.Key)
+            if len(notes) > 0 {
+                line += " (" + strings.Join(notes, ", ") + ")"
+            }
+            if entry.Description != "" {
+                line += " - " + entry.Description
+            }
+            fmt.Println(line)
+        }
+        return nil
+    },
+}
+
+var unsetCmd = &cobra.Command{
+    Use:   "unset KEY [KEY2 ...]",
+    Short: "Delete one or more secrets",
+    Args:  cobra.MinimumNArgs(1),
+    RunE: func(cmd *cobra.Command, args []string) error {
+        cfg, err := loadProjectConfig()
+        if err != nil {
+            return err
+        }
+
+        creds, err := config.LoadCredentials(profileFlag(), cfg)
+        if err != nil {
+            return withCode(exitAuth, err)
+        }
+
+        var masterKey []byte
+        if cfg.OpaqueKeys {
+            if masterKey, err = config.LoadMasterKey(); err != nil {
+                return fmt.Errorf("Error loading encryption key: %w", err)
+            }
+        }
+
+        if err := confirmProtected(cfg); err != nil {
+            return err
+        }
+        cli := client.New(creds.Server, creds.Token)
+
+        result := &keyResult{Project: cfg.Project, Environment: cfg.Environment}
+        var errs []error
+        for _, key := range args {
+            err := cli.DeleteSecret(cfg.Project, cfg.Environment, serverKey(cfg, masterKey, key))
+            if errors.Is(err, client.ErrNotFound) {
+                // Report the name, not an opaque lookup ID.
+                err = fmt.Errorf("secret %s %w", key, client.ErrNotFound)
+                if secret, getErr := cli.GetSecret(cfg.Project, cfg.Environment, serverKey(cfg, masterKey, key)); getErr == nil && secret.InheritedFrom != "" {
+                    err = fmt.Errorf("%s is inherited from %s; unset it there with --env %s: %w", key, secret.InheritedFrom, secret.InheritedFrom, client.ErrNotFound)
+                }
+            }
+            if err != nil {
+                err = fmt.Errorf("Error removing %s: %w", key, err)
+                warn("%v", err)
+                result.Failed = append(result.Failed, keyFailed{Key: key, Error: err.Error()})
+                errs = append(errs, err)
+                continue
+            }
+            success("Removed %s", key)
+            result.Changed = append(result.Changed, key)
+        }
+
+        return result.finish(errs)
     },
 }
 
+// profileFlag returns the --profile value, or "" when not given.
+func profileFlag() string {
+    profile, _ := rootCmd.PersistentFlags().GetString("profile")
+    return profile
+}
+
 func init() {
-    initCmd.Flags().String("env", "production", "Environment name")
+    rootCmd.PersistentFlags().String("profile", "", "Credentials profile to use (overrides HUSH_PROFILE and hush.yaml)")
+    rootCmd.PersistentFlags().String("env", "", "Environment to use (overrides HUSH_ENV and hush.yaml)")
+    rootCmd.PersistentFlags().Bool("yes", false, "Skip the confirmation for protected environments")
+    rootCmd.PersistentFlags().String("output", "text", "Output format: text or json")
+    rootCmd.PersistentFlags().BoolP("quiet", "q", false, "Print only results, without confirmations or hints")
+    rootCmd.PersistentFlags().String("group", "", "Act on a shared group instead of the project (set, unset, get, list, generate, inherit)")
+    initCmd.Flags().Bool("opaque-keys", false, "Encrypt key names so the server only sees lookup IDs")
+    setCmd.Flags().Bool("stdin", false, "Read the value of KEY from stdin")
+    setCmd.Flags().String("from-file", "", "Read the value of KEY from a file")
+    setCmd.Flags().Bool("as-file", false, "Store KEY as a file secret that pull writes out as a file")
+    setCmd.Flags().String("file-name", "", "File name for --as-file (default: the --from-file name)")
+    setCmd.Flags().String("mode", "0600", "Permissions for --as-file, readable by the owner only")
+    setCmd.Flags().Bool("generate", false, "Generate random values for bare keys")
+    setCmd.Flags().Int("length", 32, "Length of generated values")
+    setCmd.Flags().String("charset", defaultCharset, "Characters for generated values: alnum, alpha, lower, digits, hex, symbols or a literal set")
+    addExpiryFlags(setCmd)
+    addMetadataFlags(setCmd)
+    listCmd.Flags().StringSlice("tag", nil, "Only list secrets with these tags")
+    pullCmd.Flags().Bool("watch", false, "Keep running and re-pull whenever a secret changes")
+    pullCmd.Flags().Bool("raw", false, "Write values as stored, without resolving ${...} references")
+    pullCmd.Flags().Bool("all", false, "Pull every project declared in the monorepo's root hush.yaml")
+
+    rootCmd.SetFlagErrorFunc(func(cmd *cobra.Command, err error) error {
+        return withCode(exitUsage, err)
+    })
 
     rootCmd.AddCommand(loginCmd)
     rootCmd.AddCommand(initCmd)
     rootCmd.AddCommand(setCmd)
     rootCmd.AddCommand(pullCmd)
     rootCmd.AddCommand(listCmd)
+    rootCmd.AddCommand(unsetCmd)
+    rootCmd.AddCommand(opaqueKeysCmd)
+    rootCmd.AddCommand(contextCmd)
+    rootCmd.AddCommand(configCmd)
+    rootCmd.AddCommand(checkCmd)
+    rootCmd.AddCommand(getCmd)
+    rootCmd.AddCommand(generateCmd)
+    rootCmd.AddCommand(runCmd)
+    rootCmd.AddCommand(renderCmd)
+    rootCmd.AddCommand(groupsCmd)
+    rootCmd.AddCommand(inheritCmd)
+    rootCmd.AddCommand(statusCmd)
+    rootCmd.AddCommand(searchCmd)
+    rootCmd.AddCommand(scanCmd)
 }
 
 func main() {
     if err := rootCmd.Execute(); err != nil {
-        os.Exit(1)
+        code := exitCode(err)
+        if !started && code == exitError {
+            code = exitUsage
+        }
+        var status exitStatus
+        if !errors.As(err, &status) {
+            reportError(err, code)
+        }
+        os.Exit(code)
     }
-}
\ No newline at end of file
+}
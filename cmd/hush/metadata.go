@@ -0,0 +1,165 @@
+package main
+
+import (
+    "fmt"
+    "slices"
+    "strings"
+
+    "github.com/spf13/cobra"
+    "github.com/adith2005-20/hush/pkg/client"
+    "github.com/adith2005-20/hush/pkg/config"
+    "github.com/adith2005-20/hush/pkg/crypto"
+)
+
+// secretMeta is the free-form metadata stored alongside a secret, in
+// plaintext, or encrypted in opaque_keys projects.
+type secretMeta struct {
+    Description string   `json:"description,omitempty"`
+    Owner       string   `json:"owner,omitempty"`
+    Tags        []string `json:"tags,omitempty"`
+    Link        string   `json:"link,omitempty"`
+}
+
+// metaChange is the metadata given on the command line. Nil fields keep
+// what the secret already has.
+type metaChange struct {
+    Description *string
+    Owner       *string
+    Tags        *[]string
+    Link        *string
+}
+
+func addMetadataFlags(cmd *cobra.Command) {
+    cmd.Flags().String("desc", "", "What the secret is for (\"\" clears it)")
+    cmd.Flags().String("owner", "", "Who is responsible for the secret")
+    cmd.Flags().StringSlice("tag", nil, "Tag the secret, replacing its tags (repeatable; \"\" clears them)")
+    cmd.Flags().String("link", "", "Where the secret is managed, such as a vendor console URL")
+}
+
+// metadataFlags returns the metadata given with --desc, --owner, --tag or
+// --link, or nil if none of them were.
+func metadataFlags(cmd *cobra.Command) (*metaChange, error) {
+    change := &metaChange{}
+    changed := false
+    for name, field := range map[string]**string{"desc": &change.Description, "owner": &change.Owner, "link": &change.Link} {
+        if cmd.Flags().Changed(name) {
+            value, _ := cmd.Flags().GetString(name)
+            value = strings.TrimSpace(value)
+            *field = &value
+            changed = true
+        }
+    }
+    if cmd.Flags().Changed("tag") {
+        raw, _ := cmd.Flags().GetStringSlice("tag")
+        tags, err := parseTags(raw)
+        if err != nil {
+            return nil, err
+        }
+        change.Tags = &tags
+        changed = true
+    }
+    if !changed {
+        return nil, nil
+    }
+    return change, nil
+}
+
+// parseTags trims and de-duplicates tags, which may not contain spaces.
+func parseTags(raw []string) ([]string, error) {
+    tags := []string{}
+    for _, tag := range raw {
+        tag = strings.TrimSpace(tag)
+        if tag == "" || slices.Contains(tags, tag) {
+            continue
+        }
+        if strings.ContainsAny(tag, " \t") {
+            return nil, withCode(exitUsage, fmt.Errorf("invalid tag %q: tags cannot contain spaces", tag))
+        }
+        tags = append(tags, tag)
+    }
+    return tags, nil
+}
+
+// apply updates m with the fields given on the command line.
+func (c metaChange) apply(m *secretMeta) {
+    if c.Description != nil {
+        m.Description = *c.Description
+    }
+    if c.Owner != nil {
+        m.Owner = *c.Owner
+    }
+    if c.Tags != nil {
+        m.Tags = *c.Tags
+    }
+    if c.Link != nil {
+        m.Link = *c.Link
+    }
+}
+
+// hasTags reports whether m carries every one of tags.
+func (m secretMeta) hasTags(tags []string) bool {
+    for _, tag := range tags {
+        if !slices.Contains(m.Tags, tag) {
+            return false
+        }
+    }
+    return true
+}
+
+// secretMetadata returns the metadata of a secret fetched from the server,
+// decrypting it for opaque-key projects.
+func secretMetadata(secret client.Secret, masterKey []byte) (secretMeta, error) {
+    m := secretMeta{Description: secret.Description, Owner: secret.Owner, Tags: secret.Tags, Link: secret.Link}
+    if secret.Name == "" {
+        return m, nil
+    }
+
+    decrypt := func(v string) (string, error) {
+        if v == "" {
+            return "", nil
+        }
+        return crypto.Decrypt(v, masterKey)
+    }
+    var err error
+    for _, field := range []*string{&m.Description, &m.Owner, &m.Link} {
+        if *field, err = decrypt(*field); err != nil {
+            return secretMeta{}, fmt.Errorf("failed to decrypt metadata: %w", err)
+        }
+    }
+    m.Tags = make([]string, len(secret.Tags))
+    for i, tag := range secret.Tags {
+        if m.Tags[i], err = decrypt(tag); err != nil {
+            return secretMeta{}, fmt.Errorf("failed to decrypt metadata: %w", err)
+        }
+    }
+    return m, nil
+}
+
+// store sets the metadata fields of a secret about to be stored, encrypting
+// them in opaque-key projects so they don't give away what the key is.
+func (m secretMeta) store(cfg *config.Config, masterKey []byte, secret *client.Secret) error {
+    secret.Description, secret.Owner, secret.Tags, secret.Link = m.Description, m.Owner, m.Tags, m.Link
+    if !cfg.OpaqueKeys {
+        return nil
+    }
+
+    encrypt := func(v string) (string, error) {
+        if v == "" {
+            return "", nil
+        }
+        return crypto.Encrypt(v, masterKey)
+    }
+    var err error
+    for _, field := range []*string{&secret.Description, &secret.Owner, &secret.Link} {
+        if *field, err = encrypt(*field); err != nil {
+            return err
+        }
+    }
+    secret.Tags = make([]string, len(m.Tags))
+    for i, tag := range m.Tags {
+        if secret.Tags[i], err = encrypt(tag); err != nil {
+            return err
+        }
+    }
+    return nil
+}
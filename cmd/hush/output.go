@@ -0,0 +1,133 @@
+package main
+
+import (
+    "encoding/json"
+    "errors"
+    "fmt"
+    "net"
+    "os"
+
+    "github.com/adith2005-20/hush/pkg/client"
+    "github.com/adith2005-20/hush/pkg/config"
+)
+
+// Exit codes scripts can rely on.
+const (
+    exitError    = 1 // anything not listed below
+    exitUsage    = 2 // bad flags or arguments
+    exitAuth     = 3 // the server rejected the token or its access, or not logged in
+    exitNotFound = 4 // a secret, profile or hush.yaml does not exist
+    exitConflict = 5 // the change clashes with existing state
+    exitNetwork  = 6 // the server could not be reached
+    exitDecrypt  = 7 // a value could not be decrypted with the master key
+    exitFound    = 8 // hush scan found secrets
+)
+
+// codedError attaches an exit code to an error.
+type codedError struct {
+    code int
+    err  error
+}
+
+func (e *codedError) Error() string { return e.err.Error() }
+func (e *codedError) Unwrap() error { return e.err }
+
+// withCode makes err exit with code unless it already carries one.
+func withCode(code int, err error) error {
+    if err == nil {
+        return nil
+    }
+    var coded *codedError
+    if errors.As(err, &coded) {
+        return err
+    }
+    return &codedError{code: code, err: err}
+}
+
+// exitStatus is returned by hush run to exit with the child's status
+// without reporting an error of its own.
+type exitStatus int
+
+func (s exitStatus) Error() string { return fmt.Sprintf("exit status %d", int(s)) }
+
+// exitCode maps an error returned by a command to the process exit code.
+func exitCode(err error) int {
+    var coded *codedError
+    var status exitStatus
+    var opErr *net.OpError
+    var dnsErr *net.DNSError
+    switch {
+    case errors.As(err, &status):
+        return int(status)
+    case errors.As(err, &coded):
+        return coded.code
+    case errors.Is(err, client.ErrUnauthorized), errors.Is(err, client.ErrForbidden):
+        return exitAuth
+    case errors.Is(err, client.ErrNotFound), errors.Is(err, config.ErrNoProjectConfig):
+        return exitNotFound
+    case errors.Is(err, client.ErrConflict):
+        return exitConflict
+    case errors.As(err, &opErr), errors.As(err, &dnsErr):
+        return exitNetwork
+    }
+    return exitError
+}
+
+// jsonOutput reports whether --output json was given.
+func jsonOutput() bool {
+    format, _ := rootCmd.PersistentFlags().GetString("output")
+    return format == "json"
+}
+
+func quiet() bool {
+    q, _ := rootCmd.PersistentFlags().GetBool("quiet")
+    return q
+}
+
+// success prints a confirmation line to stdout in text mode, unless --quiet.
+func success(format string, a ...interface{}) {
+    if !jsonOutput() && !quiet() {
+        fmt.Printf("✓ "+format+"\n", a...)
+    }
+}
+
+// info prints supporting text, such as next steps, under the same rules as
+// success.
+func info(format string, a ...interface{}) {
+    if !jsonOutput() && !quiet() {
+        fmt.Printf(format+"\n", a...)
+    }
+}
+
+// warn prints a diagnostic to stderr, which stays free of results so it
+// can be shown to the user while stdout is parsed.
+func warn(format string, a ...interface{}) {
+    if jsonOutput() {
+        emitTo(os.Stderr, map[string]string{"warning": fmt.Sprintf(format, a...)})
+        return
+    }
+    fmt.Fprintf(os.Stderr, "⚠️  "+format+"\n", a...)
+}
+
+// emit writes a command's result to stdout in JSON mode. It is a no-op in
+// text mode, where commands print their own prose.
+func emit(v interface{}) {
+    if jsonOutput() {
+        emitTo(os.Stdout, v)
+    }
+}
+
+func emitTo(f *os.File, v interface{}) {
+    enc := json.NewEncoder(f)
+    enc.SetIndent("", "  ")
+    enc.Encode(v)
+}
+
+// reportError prints a failed command's error to stderr.
+func reportError(err error, code int) {
+    if jsonOutput() {
+        emitTo(os.Stderr, map[string]interface{}{"error": err.Error(), "exit_code": code})
+        return
+    }
+    fmt.Fprintf(os.Stderr, "❌ %v\n", err)
+}
@@ -0,0 +1,181 @@
+package main
+
+import (
+    "bytes"
+    "encoding/base64"
+    "encoding/json"
+    "errors"
+    "fmt"
+    "os"
+    "path/filepath"
+    "text/template"
+
+    "github.com/spf13/cobra"
+    "github.com/adith2005-20/hush/pkg/client"
+    "github.com/adith2005-20/hush/pkg/config"
+)
+
+var renderCmd = &cobra.Command{
+    Use:   "render TEMPLATE",
+    Short: "Render a config file template from secrets",
+    Long: `Render a Go text/template with the environment's secrets and print it,
+or write it to a file with -o. Secrets are fields of the template's data,
+and referring to one that is not set is an error:
+
+  listen {{ .PORT }};
+  password: {{ .DB_PASSWORD | json }}
+  port: {{ get "PORT" | default "8080" }}
+
+Helper functions:
+  get KEY            the value of KEY, or "" if it is not set
+  default DEF VALUE  VALUE, or DEF if VALUE is empty
+  required MSG VALUE VALUE, or fail with MSG if it is empty
+  base64 VALUE       VALUE base64-encoded
+  base64decode VALUE VALUE base64-decoded
+  json VALUE         VALUE as a JSON string
+
+Templates listed under templates: in hush.yaml are rendered by every
+'hush pull', next to the output file. An environment can list its own
+under environments:, replacing the project's.`,
+    Args: cobra.ExactArgs(1),
+    RunE: func(cmd *cobra.Command, args []string) error {
+        out, _ := cmd.Flags().GetString("out")
+        raw, _ := cmd.Flags().GetBool("raw")
+
+        cfg, err := loadProjectConfig()
+        if err != nil {
+            return err
+        }
+        if err := confirmProtected(cfg); err != nil {
+            return err
+        }
+
+        creds, err := config.LoadCredentials(profileFlag(), cfg)
+        if err != nil {
+            return withCode(exitAuth, err)
+        }
+
+        masterKey, err := config.LoadMasterKey()
+        if err != nil {
+            return fmt.Errorf("Error loading encryption key: %w", err)
+        }
+
+        cli := client.New(creds.Server, creds.Token)
+        vars, err := fetchSecrets(cfg, cli, masterKey)
+        if err != nil {
+            return err
+        }
+        if !raw {
+            if vars, err = resolveRefs(cfg, cli, masterKey, vars); err != nil {
+                return err
+            }
+        }
+        if vars, err = applySchema(cfg, vars); err != nil {
+            return err
+        }
+
+        data, err := renderTemplate(args[0], vars)
+        if err != nil {
+            return err
+        }
+
+        if out == "" {
+            os.Stdout.Write(data)
+            return nil
+        }
+        if err := writeFileAtomic(out, data, 0600); err != nil {
+            return fmt.Errorf("Error writing to %s: %w", out, err)
+        }
+        success("Rendered %s to %s", args[0], out)
+        emit(map[string]string{"template": args[0], "path": out})
+        return nil
+    },
+}
+
+// renderTemplates renders every template declared in hush.yaml.
+func renderTemplates(cfg *config.Config, vars []envVar) error {
+    for i, t := range cfg.Templates {
+        if t.Template == "" || t.Path == "" {
+            return fmt.Errorf("templates[%d] in %s needs both template and path", i, cfg.File)
+        }
+        src, dest := cfg.ProjectPath(t.Template), cfg.ProjectPath(t.Path)
+        data, err := renderTemplate(src, vars)
+        if err != nil {
+            return err
+        }
+        if err := os.MkdirAll(filepath.Dir(dest), 0755); err != nil {
+            return fmt.Errorf("Error creating %s: %w", filepath.Dir(dest), err)
+        }
+        if err := writeFileAtomic(dest, data, 0600); err != nil {
+            return fmt.Errorf("Error writing to %s: %w", dest, err)
+        }
+    }
+    return nil
+}
+
+// renderTemplate executes the template file at path with vars as its data.
+func renderTemplate(path string, vars []envVar) ([]byte, error) {
+    values := make(map[string]string, len(vars))
+    for _, v := range vars {
+        if data, ok := decodeBinary(v.Value); ok {
+            values[v.Name] = string(data)
+        } else {
+            values[v.Name] = v.Value
+        }
+    }
+
+    text, err := os.ReadFile(path)
+    if err != nil {
+        return nil, withCode(exitNotFound, fmt.Errorf("Error reading template: %w", err))
+    }
+
+    tmpl, err := template.New(filepath.Base(path)).
+        Option("missingkey=error").
+        Funcs(templateFuncs(values)).
+        Parse(string(text))
+    if err != nil {
+        return nil, fmt.Errorf("Error parsing %s: %w", path, err)
+    }
+
+    var out bytes.Buffer
+    if err := tmpl.Execute(&out, values); err != nil {
+        return nil, fmt.Errorf("Error rendering %s: %w", path, err)
+    }
+    return out.Bytes(), nil
+}
+
+func templateFuncs(values map[string]string) template.FuncMap {
+    return template.FuncMap{
+        "get": func(key string) string {
+            return values[key]
+        },
+        "default": func(def, value string) string {
+            if value == "" {
+                return def
+            }
+            return value
+        },
+        "required": func(msg, value string) (string, error) {
+            if value == "" {
+                return "", errors.New(msg)
+            }
+            return value, nil
+        },
+        "base64": func(value string) string {
+            return base64.StdEncoding.EncodeToString([]byte(value))
+        },
+        "base64decode": func(value string) (string, error) {
+            data, err := base64.StdEncoding.DecodeString(value)
+            return string(data), err
+        },
+        "json": func(value string) (string, error) {
+            data, err := json.Marshal(value)
+            return string(data), err
+        },
+    }
+}
+
+func init() {
+    renderCmd.Flags().StringP("out", "o", "", "Write to this file (mode 0600) instead of stdout")
+    renderCmd.Flags().Bool("raw", false, "Render values as stored, without resolving ${...} references")
+}
@@ -0,0 +1,110 @@
+package main
+
+import (
+    "errors"
+    "fmt"
+    "os"
+    "os/exec"
+    "os/signal"
+    "syscall"
+
+    "github.com/spf13/cobra"
+    "github.com/adith2005-20/hush/pkg/client"
+    "github.com/adith2005-20/hush/pkg/config"
+)
+
+var runCmd = &cobra.Command{
+    Use:   "run [--] COMMAND [ARGS...]",
+    Short: "Run a command with secrets in its environment",
+    Long: `Run a command with the environment's secrets added to its environment,
+without writing them to the project directory:
+
+  hush run -- npm start
+  hush run --env staging ./migrate.sh
+
+File secrets and binary values are written to a private temporary
+directory, their keys are set to the files' paths, and the directory is
+removed when the command exits. hush exits with the command's status.`,
+    Args: cobra.MinimumNArgs(1),
+    RunE: func(cmd *cobra.Command, args []string) error {
+        cfg, err := loadProjectConfig()
+        if err != nil {
+            return err
+        }
+        if err := confirmProtected(cfg); err != nil {
+            return err
+        }
+
+        creds, err := config.LoadCredentials(profileFlag(), cfg)
+        if err != nil {
+            return withCode(exitAuth, err)
+        }
+
+        masterKey, err := config.LoadMasterKey()
+        if err != nil {
+            return fmt.Errorf("Error loading encryption key: %w", err)
+        }
+
+        cli := client.New(creds.Server, creds.Token)
+        vars, err := fetchSecrets(cfg, cli, masterKey)
+        if err != nil {
+            return err
+        }
+        if raw, _ := cmd.Flags().GetBool("raw"); !raw {
+            if vars, err = resolveRefs(cfg, cli, masterKey, vars); err != nil {
+                return err
+            }
+        }
+        if vars, err = applySchema(cfg, vars); err != nil {
+            return err
+        }
+
+        dir, err := os.MkdirTemp("", "hush-run-*")
+        if err != nil {
+            return err
+        }
+        defer os.RemoveAll(dir)
+        if vars, err = writeFiles(dir, vars, true); err != nil {
+            return err
+        }
+
+        child := exec.Command(args[0], args[1:]...)
+        child.Stdin, child.Stdout, child.Stderr = os.Stdin, os.Stdout, os.Stderr
+        child.Env = os.Environ()
+        for _, v := range vars {
+            child.Env = append(child.Env, cfg.Prefix+v.Name+"="+v.Value)
+        }
+
+        // Ctrl+C reaches the child through the terminal; hush keeps running
+        // so it can remove the temporary directory once the child exits.
+        signals := make(chan os.Signal, 1)
+        signal.Notify(signals, os.Interrupt, syscall.SIGTERM, syscall.SIGHUP)
+        defer signal.Stop(signals)
+
+        if err := child.Start(); err != nil {
+            return fmt.Errorf("Error starting %s: %w", args[0], err)
+        }
+        go func() {
+            for sig := range signals {
+                if sig != os.Interrupt {
+                    child.Process.Signal(sig)
+                }
+            }
+        }()
+
+        err = child.Wait()
+        var exitErr *exec.ExitError
+        if errors.As(err, &exitErr) {
+            if ws, ok := exitErr.Sys().(syscall.WaitStatus); ok && ws.Signaled() {
+                return exitStatus(128 + int(ws.Signal()))
+            }
+            return exitStatus(exitErr.ExitCode())
+        }
+        return err
+    },
+}
+
+func init() {
+    runCmd.Flags().SetInterspersed(false)
+    runCmd.Flags().Bool("raw", false, "Pass values as stored, without resolving ${...} references")
+}
@@ -0,0 +1,391 @@
+package main
+
+import (
+    "bufio"
+    "bytes"
+    "encoding/base64"
+    "errors"
+    "fmt"
+    "io/fs"
+    "net/url"
+    "os"
+    "os/exec"
+    "path/filepath"
+    "strconv"
+    "strings"
+
+    "github.com/spf13/cobra"
+    "github.com/adith2005-20/hush/pkg/client"
+    "github.com/adith2005-20/hush/pkg/config"
+)
+
+// maxScanSize is the largest file hush scan reads; bigger ones are skipped.
+const maxScanSize = 10 << 20
+
+// needle is one form of a secret's value that hush scan looks for.
+type needle struct {
+    Key      string
+    Encoding string
+    Text     []byte
+}
+
+// scanFinding is one occurrence of a secret. Commit is set for matches in
+// git history. The value itself is never reported.
+type scanFinding struct {
+    File     string `json:"file"`
+    Line     int    `json:"line"`
+    Commit   string `json:"commit,omitempty"`
+    Key      string `json:"key"`
+    Encoding string `json:"encoding"`
+}
+
+// scanResult is the JSON result of hush scan.
+type scanResult struct {
+    Project     string        `json:"project"`
+    Environment string        `json:"environment"`
+    Files       int           `json:"files"`
+    Skipped     []string      `json:"skipped,omitempty"`
+    Findings    []scanFinding `json:"findings"`
+}
+
+var scanCmd = &cobra.Command{
+    Use:   "scan [PATH]",
+    Short: "Find the environment's secrets in files and git history",
+    Long: `Decrypt the current environment's secrets and search PATH (default: the
+current directory) for their values, as written or base64 or URL encoded.
+Each occurrence is reported by file, line and key name; values are never
+printed. hush scan exits 8 when it finds anything, so it can gate CI:
+
+  hush scan
+  hush scan --env production --history
+
+Inside a git repository only files git would track are searched, so an
+ignored .env is not reported but a committed one is. Elsewhere every file is
+searched except the project's own output file, output.files and rendered
+templates. --history also searches every line added in 'git log -p --all'.
+
+Values shorter than --min-length (default 8) are skipped, as short values
+like "true" or "3000" match everywhere.`,
+    Args: cobra.MaximumNArgs(1),
+    RunE: func(cmd *cobra.Command, args []string) error {
+        history, _ := cmd.Flags().GetBool("history")
+        minLength, _ := cmd.Flags().GetInt("min-length")
+
+        root := "."
+        if len(args) == 1 {
+            root = args[0]
+        }
+        if _, err := os.Stat(root); err != nil {
+            return withCode(exitUsage, err)
+        }
+
+        cfg, err := loadProjectConfig()
+        if err != nil {
+            return err
+        }
+
+        creds, err := config.LoadCredentials(profileFlag(), cfg)
+        if err != nil {
+            return withCode(exitAuth, err)
+        }
+
+        masterKey, err := config.LoadMasterKey()
+        if err != nil {
+            return fmt.Errorf("Error loading encryption key: %w", err)
+        }
+
+        cli := client.New(creds.Server, creds.Token)
+        vars, err := fetchSecrets(cfg, cli, masterKey)
+        if err != nil {
+            return err
+        }
+        if vars, err = resolveRefs(cfg, cli, masterKey, vars); err != nil {
+            return err
+        }
+
+        result := scanResult{Project: cfg.Project, Environment: cfg.Environment, Findings: []scanFinding{}}
+        var needles []needle
+        for _, v := range vars {
+            found := valueNeedles(v)
+            if len(found) == 0 || len(found[0].Text) < minLength {
+                result.Skipped = append(result.Skipped, v.Name)
+                continue
+            }
+            needles = append(needles, found...)
+        }
+
+        files, err := scanPaths(cfg, root)
+        if err != nil {
+            return err
+        }
+        for _, path := range files {
+            data, err := readScanFile(path)
+            if err != nil {
+                warn("Skipping %s: %v", path, err)
+                continue
+            }
+            if data == nil {
+                continue
+            }
+            result.Files++
+            for _, f := range scanText(data, needles, nil) {
+                f.File = path
+                result.Findings = append(result.Findings, f)
+            }
+        }
+
+        if history {
+            found, err := scanHistory(root, needles)
+            if err != nil {
+                return err
+            }
+            result.Findings = append(result.Findings, found...)
+        }
+
+        if jsonOutput() {
+            emit(result)
+        } else {
+            printScan(result)
+        }
+        if len(result.Findings) > 0 {
+            return exitStatus(exitFound)
+        }
+        return nil
+    },
+}
+
+// valueNeedles returns the forms of v's value to look for: the value
+// itself, then its base64 and URL encodings where they differ from it.
+func valueNeedles(v envVar) []needle {
+    value := []byte(v.Value)
+    if data, ok := decodeBinary(v.Value); ok {
+        value = data
+    }
+    if len(bytes.TrimSpace(value)) == 0 {
+        return nil
+    }
+
+    needles := []needle{{Key: v.Name, Encoding: "plain", Text: value}}
+    seen := map[string]bool{string(value): true}
+    add := func(encoding, text string) {
+        if !seen[text] {
+            seen[text] = true
+            needles = append(needles, needle{Key: v.Name, Encoding: encoding, Text: []byte(text)})
+        }
+    }
+    // Unpadded encodings also find padded ones, which only add "=".
+    add("base64", base64.RawStdEncoding.EncodeToString(value))
+    add("base64", base64.RawURLEncoding.EncodeToString(value))
+    add("url", url.QueryEscape(string(value)))
+    add("url", url.PathEscape(string(value)))
+    return needles
+}
+
+// scanPaths lists the files to search under root. In a git work tree that
+// is what git ls-files reports as tracked or addable; elsewhere it is every
+// file but the ones hush itself writes secrets to.
+func scanPaths(cfg *config.Config, root string) ([]string, error) {
+    if info, err := os.Stat(root); err == nil && !info.IsDir() {
+        return []string{root}, nil
+    }
+
+    out, err := exec.Command("git", "-C", root, "ls-files", "-z", "--cached", "--others", "--exclude-standard").Output()
+    if err == nil {
+        var paths []string
+        for _, name := range strings.Split(string(out), "\x00") {
+            if name != "" {
+                paths = append(paths, filepath.Join(root, name))
+            }
+        }
+        return paths, nil
+    }
+
+    skip := map[string]bool{}
+    for _, path := range []string{cfg.OutputPath(), cfg.FilesPath()} {
+        skip[absPath(path)] = true
+    }
+    for _, t := range cfg.Templates {
+        if t.Path != "" {
+            skip[absPath(cfg.ProjectPath(t.Path))] = true
+        }
+    }
+
+    var paths []string
+    err = filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+        if err != nil {
+            return err
+        }
+        if skip[absPath(path)] || (d.IsDir() && d.Name() == ".git") {
+            if d.IsDir() {
+                return filepath.SkipDir
+            }
+            return nil
+        }
+        if d.Type().IsRegular() {
+            paths = append(paths, path)
+        }
+        return nil
+    })
+    return paths, err
+}
+
+func absPath(path string) string {
+    if abs, err := filepath.Abs(path); err == nil {
+        return abs
+    }
+    return path
+}
+
+// readScanFile reads a file to search. It returns nil for files that no
+// longer exist, such as deletions git still tracks, and for directories.
+func readScanFile(path string) ([]byte, error) {
+    info, err := os.Stat(path)
+    if errors.Is(err, fs.ErrNotExist) {
+        return nil, nil
+    }
+    if err != nil {
+        return nil, err
+    }
+    if !info.Mode().IsRegular() {
+        return nil, nil
+    }
+    if info.Size() > maxScanSize {
+        return nil, fmt.Errorf("larger than %d MiB", maxScanSize>>20)
+    }
+    return os.ReadFile(path)
+}
+
+// scanText finds every needle in text, reporting each key at most once per
+// line. lines maps text's line numbers (from 0) to the ones reported; nil
+// reports them as they are, counted from 1.
+func scanText(text []byte, needles []needle, lines []int) []scanFinding {
+    var findings []scanFinding
+    seen := map[string]bool{}
+    for _, n := range needles {
+        for offset := 0; ; {
+            i := bytes.Index(text[offset:], n.Text)
+            if i < 0 {
+                break
+            }
+            offset += i
+            line := bytes.Count(text[:offset], []byte("\n"))
+            if lines != nil {
+                line = lines[line]
+            } else {
+                line++
+            }
+            id := n.Key + ":" + strconv.Itoa(line)
+            if !seen[id] {
+                seen[id] = true
+                findings = append(findings, scanFinding{Line: line, Key: n.Key, Encoding: n.Encoding})
+            }
+            offset += len(n.Text)
+        }
+    }
+    return findings
+}
+
+// scanHistory searches the lines every commit in the repository at dir
+// added. Consecutive added lines are searched together, so multi-line
+// values such as PEM keys are found too.
+func scanHistory(dir string, needles []needle) ([]scanFinding, error) {
+    git := exec.Command("git", "-C", dir, "log", "-p", "--all", "-U0", "--no-color", "--no-ext-diff",
+        "--format=commit %H", "--", ".")
+    var stderr bytes.Buffer
+    git.Stderr = &stderr
+    out, err := git.StdoutPipe()
+    if err != nil {
+        return nil, err
+    }
+    if err := git.Start(); err != nil {
+        return nil, fmt.Errorf("--history needs git: %w", err)
+    }
+
+    var findings []scanFinding
+    var commit, file string
+    var block []byte
+    var lines []int
+    next := 0
+    flush := func() {
+        if len(block) > 0 && file != "" {
+            for _, f := range scanText(block, needles, lines) {
+                f.File, f.Commit = file, commit[:min(len(commit), 12)]
+                findings = append(findings, f)
+            }
+        }
+        block, lines = block[:0], lines[:0]
+    }
+
+    scanner := bufio.NewScanner(out)
+    scanner.Buffer(make([]byte, 64*1024), maxScanSize)
+    for scanner.Scan() {
+        line := scanner.Text()
+        switch {
+        case strings.HasPrefix(line, "commit "):
+            flush()
+            commit, file = strings.TrimPrefix(line, "commit "), ""
+        case strings.HasPrefix(line, "+++ "):
+            flush()
+            file = ""
+            if name, ok := strings.CutPrefix(line, "+++ b/"); ok {
+                file = name
+            }
+        case strings.HasPrefix(line, "@@ "):
+            flush()
+            // @@ -a,b +c,d @@: added lines are numbered from c.
+            fields := strings.Fields(line)
+            if len(fields) > 2 {
+                start, _, _ := strings.Cut(strings.TrimPrefix(fields[2], "+"), ",")
+                next, _ = strconv.Atoi(start)
+            }
+        case strings.HasPrefix(line, "+"):
+            if len(lines) > 0 {
+                block = append(block, '\n')
+            }
+            block = append(block, line[1:]...)
+            lines = append(lines, next)
+            next++
+        default:
+            flush()
+        }
+    }
+    flush()
+    if err := scanner.Err(); err != nil {
+        git.Process.Kill()
+        git.Wait()
+        return nil, fmt.Errorf("Error reading git history: %w", err)
+    }
+    if err := git.Wait(); err != nil {
+        return nil, withCode(exitUsage, fmt.Errorf("--history: git log failed: %s", strings.TrimSpace(stderr.String())))
+    }
+    return findings, nil
+}
+
+func printScan(result scanResult) {
+    if len(result.Findings) == 0 {
+        success("No secrets from %s/%s found in %d files", result.Project, result.Environment, result.Files)
+        return
+    }
+
+    // Quiet mode prints bare locations, one per line.
+    info("❌ Found secrets from %s/%s in %d places:", result.Project, result.Environment, len(result.Findings))
+    for _, f := range result.Findings {
+        location := fmt.Sprintf("%s:%d", f.File, f.Line)
+        if f.Commit != "" {
+            location = f.Commit + " " + location
+        }
+        switch {
+        case quiet():
+            fmt.Printf("%s %s\n", location, f.Key)
+        case f.Encoding != "plain":
+            fmt.Printf("  %s  %s (%s)\n", location, f.Key, f.Encoding)
+        default:
+            fmt.Printf("  %s  %s\n", location, f.Key)
+        }
+    }
+}
+
+func init() {
+    scanCmd.Flags().Bool("history", false, "Also search lines added in git history")
+    scanCmd.Flags().Int("min-length", 8, "Skip values shorter than this")
+}
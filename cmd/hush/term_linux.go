@@ -0,0 +1,8 @@
+package main
+
+import "golang.org/x/sys/unix"
+
+const (
+    ioctlGetTermios = unix.TCGETS
+    ioctlSetTermios = unix.TCSETS
+)
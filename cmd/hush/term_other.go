@@ -0,0 +1,18 @@
+//go:build !(linux || darwin || freebsd || netbsd || openbsd || dragonfly)
+
+package main
+
+import (
+    "errors"
+    "os"
+)
+
+func isTerminal(f *os.File) bool {
+    fi, err := f.Stat()
+    return err == nil && fi.Mode()&os.ModeCharDevice != 0
+}
+
+// readHidden is not supported here; values can still be piped with --stdin.
+func readHidden(f *os.File) (string, error) {
+    return "", errors.New("hidden input is not supported on this platform; use --stdin")
+}
@@ -0,0 +1,40 @@
+//go:build linux || darwin || freebsd || netbsd || openbsd || dragonfly
+
+package main
+
+import (
+    "bufio"
+    "os"
+    "strings"
+
+    "golang.org/x/sys/unix"
+)
+
+func isTerminal(f *os.File) bool {
+    _, err := unix.IoctlGetTermios(int(f.Fd()), ioctlGetTermios)
+    return err == nil
+}
+
+// readHidden reads one line from the terminal f with echo turned off.
+func readHidden(f *os.File) (string, error) {
+    fd := int(f.Fd())
+    old, err := unix.IoctlGetTermios(fd, ioctlGetTermios)
+    if err != nil {
+        return "", err
+    }
+
+    hidden := *old
+    hidden.Lflag &^= unix.ECHO
+    hidden.Lflag |= unix.ICANON | unix.ISIG
+    hidden.Iflag |= unix.ICRNL
+    if err := unix.IoctlSetTermios(fd, ioctlSetTermios, &hidden); err != nil {
+        return "", err
+    }
+    defer unix.IoctlSetTermios(fd, ioctlSetTermios, old)
+
+    line, err := bufio.NewReader(f).ReadString('\n')
+    if err != nil && line == "" {
+        return "", err
+    }
+    return strings.TrimRight(line, "\r\n"), nil
+}
@@ -0,0 +1,149 @@
+package main
+
+import (
+    "database/sql"
+    "encoding/json"
+    "fmt"
+    "net/http"
+
+    "github.com/adith2005-20/hush/pkg/storage"
+)
+
+// environmentParent is the body of /api/environments.
+type environmentParent struct {
+    Project     string `json:"project"`
+    Environment string `json:"environment"`
+    // Parent is the environment secrets are inherited from, or "".
+    Parent      string `json:"parent"`
+}
+
+// environmentChain returns env followed by the environments it inherits
+// from, nearest first.
+func (s *Server) environmentChain(project, env string) ([]string, error) {
+    chain := []string{env}
+    for {
+        parent, err := s.store.GetParent(project, chain[len(chain)-1])
+        if err != nil {
+            return nil, err
+        }
+        if parent == "" {
+            return chain, nil
+        }
+        for _, e := range chain {
+            if e == parent {
+                return nil, fmt.Errorf("%s/%s has an inheritance cycle through %s", project, env, parent)
+            }
+        }
+        chain = append(chain, parent)
+    }
+}
+
+// inheritedSecrets returns the secrets of env merged with those of the
+// environments it inherits from. A key set nearer env wins, and secrets
+// from a parent are marked with the environment that holds them.
+func (s *Server) inheritedSecrets(project, env string) ([]storage.Secret, error) {
+    chain, err := s.environmentChain(project, env)
+    if err != nil {
+        return nil, err
+    }
+
+    var merged []storage.Secret
+    seen := map[string]bool{}
+    for _, e := range chain {
+        secrets, err := s.store.GetSecrets(project, e)
+        if err != nil {
+            return nil, err
+        }
+        for _, secret := range secrets {
+            if seen[secret.Key] {
+                continue
+            }
+            seen[secret.Key] = true
+            if e != env {
+                secret.InheritedFrom = e
+            }
+            merged = append(merged, secret)
+        }
+    }
+    return merged, nil
+}
+
+// inheritedSecret looks key up in env and then the environments it
+// inherits from. It returns sql.ErrNoRows when none of them sets it.
+func (s *Server) inheritedSecret(project, env, key string) (*storage.Secret, error) {
+    chain, err := s.environmentChain(project, env)
+    if err != nil {
+        return nil, err
+    }
+
+    for _, e := range chain {
+        secret, err := s.store.GetSecret(project, e, key)
+        if err == sql.ErrNoRows {
+            continue
+        }
+        if err != nil {
+            return nil, err
+        }
+        if e != env {
+            secret.InheritedFrom = e
+        }
+        return secret, nil
+    }
+    return nil, sql.ErrNoRows
+}
+
+func (s *Server) handleEnvironments(w http.ResponseWriter, r *http.Request) {
+    if r.Method == "POST" {
+        s.handleSetParent(w, r)
+        return
+    }
+
+    project := r.URL.Query().Get("project")
+    env := r.URL.Query().Get("environment")
+    if project == "" || env == "" {
+        http.Error(w, "project and environment required", http.StatusBadRequest)
+        return
+    }
+
+    parent, err := s.store.GetParent(project, env)
+    if err != nil {
+        http.Error(w, err.Error(), http.StatusInternalServerError)
+        return
+    }
+
+    json.NewEncoder(w).Encode(environmentParent{Project: project, Environment: env, Parent: parent})
+}
+
+func (s *Server) handleSetParent(w http.ResponseWriter, r *http.Request) {
+    var req environmentParent
+    if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+        http.Error(w, err.Error(), http.StatusBadRequest)
+        return
+    }
+    if req.Project == "" || req.Environment == "" {
+        http.Error(w, "project and environment required", http.StatusBadRequest)
+        return
+    }
+
+    // Refuse a parent that already inherits from the environment.
+    if req.Parent != "" {
+        chain, err := s.environmentChain(req.Project, req.Parent)
+        if err != nil {
+            http.Error(w, err.Error(), http.StatusInternalServerError)
+            return
+        }
+        for _, e := range chain {
+            if e == req.Environment {
+                http.Error(w, fmt.Sprintf("%s already inherits from %s", req.Parent, req.Environment), http.StatusConflict)
+                return
+            }
+        }
+    }
+
+    if err := s.store.SetParent(req.Project, req.Environment, req.Parent); err != nil {
+        http.Error(w, err.Error(), http.StatusInternalServerError)
+        return
+    }
+
+    json.NewEncoder(w).Encode(map[string]string{"status": "ok"})
+}
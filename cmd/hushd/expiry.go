@@ -0,0 +1,183 @@
+package main
+
+import (
+    "encoding/json"
+    "fmt"
+    "log"
+    "net/http"
+    "os"
+    "time"
+
+    "github.com/spf13/cobra"
+    "github.com/adith2005-20/hush/pkg/config"
+    "github.com/adith2005-20/hush/pkg/storage"
+)
+
+// expiryCheckInterval is how often hushd start looks for secrets to send
+// expiry webhooks for.
+const expiryCheckInterval = time.Hour
+
+// Exit statuses of hushd expiring, so alerts can tell findings from
+// failures.
+const (
+    expiringFound  = 1
+    expiringFailed = 2
+)
+
+// handleExpiring lists the secrets due within ?within= (default 14d), or
+// already overdue, optionally narrowed to a project and environment.
+func (s *Server) handleExpiring(w http.ResponseWriter, r *http.Request) {
+    within := r.URL.Query().Get("within")
+    if within == "" {
+        within = "14d"
+    }
+    window, err := config.ParseDuration(within)
+    if err != nil {
+        http.Error(w, err.Error(), http.StatusBadRequest)
+        return
+    }
+
+    secrets, err := s.store.ExpiringSecrets(time.Now().Add(window))
+    if err != nil {
+        http.Error(w, err.Error(), http.StatusInternalServerError)
+        return
+    }
+
+    project := r.URL.Query().Get("project")
+    env := r.URL.Query().Get("environment")
+    matched := []storage.Secret{}
+    for _, secret := range secrets {
+        if (project == "" || secret.Project == project) && (env == "" || secret.Environment == env) {
+            matched = append(matched, secret)
+        }
+    }
+
+    json.NewEncoder(w).Encode(matched)
+}
+
+// watchExpiry sends secret.expiring once a secret comes within window of
+// its due date and secret.expired once it passes it.
+func (s *Server) watchExpiry(window time.Duration) {
+    for {
+        s.notifyExpiry(window)
+        time.Sleep(expiryCheckInterval)
+    }
+}
+
+func (s *Server) notifyExpiry(window time.Duration) {
+    now := time.Now()
+    secrets, err := s.store.ExpiringSecrets(now.Add(window))
+    if err != nil {
+        log.Printf("failed to check for expiring secrets: %v", err)
+        return
+    }
+
+    for _, secret := range secrets {
+        event := EventSecretExpiring
+        if due, _ := secret.Due(); !due.After(now) {
+            event = EventSecretExpired
+        }
+        // A new due date, after the secret is replaced, warrants new
+        // notices.
+        notice := event + " " + secret.DueAt
+        if secret.ExpiryNotice == notice {
+            continue
+        }
+
+        queued := s.queueWebhook(webhookPayload{
+            Event:       event,
+            Project:     secret.Project,
+            Environment: secret.Environment,
+            Key:         secret.Key,
+            DueAt:       secret.DueAt,
+            Owner:       plainOwner(secret),
+        })
+        if !queued {
+            continue
+        }
+        if err := s.store.MarkExpiryNotice(secret.ID, notice); err != nil {
+            log.Printf("failed to record expiry notice for %s/%s/%s: %v", secret.Project, secret.Environment, secret.Key, err)
+        }
+    }
+}
+
+var expiringCmd = &cobra.Command{
+    Use:   "expiring",
+    Short: "List secrets that have expired or are due for rotation soon",
+    Long: `List every secret whose expiry date or rotation interval falls within
+--within, or has already passed, soonest first.
+
+It exits with status 1 when anything is listed, so it can drive alerts
+from cron or a monitoring check, and with status 2 when it could not check:
+
+  hushd expiring --within 14d || notify-team`,
+    Run: func(cmd *cobra.Command, args []string) {
+        fail := func(err error) {
+            fmt.Fprintf(os.Stderr, "❌ %v\n", err)
+            os.Exit(expiringFailed)
+        }
+
+        within, _ := cmd.Flags().GetString("within")
+        window, err := config.ParseDuration(within)
+        if err != nil {
+            fail(err)
+        }
+
+        store, err := loadStore()
+        if err != nil {
+            fail(err)
+        }
+        defer store.Close()
+
+        now := time.Now()
+        secrets, err := store.ExpiringSecrets(now.Add(window))
+        if err != nil {
+            fail(err)
+        }
+
+        if len(secrets) == 0 {
+            fmt.Printf("✓ Nothing expires within %s\n", within)
+            return
+        }
+
+        for _, secret := range secrets {
+            due, _ := secret.Due()
+            state := "expires"
+            if !due.After(now) {
+                state = "expired"
+            }
+            // Opaque-key projects only expose lookup IDs to the server.
+            key := secret.Key
+            if secret.Name != "" {
+                key = "(opaque key " + shortID(secret.Key) + ")"
+            }
+            line := fmt.Sprintf("  %s/%s/%s  %s %s UTC", secret.Project, secret.Environment, key, state, secret.DueAt)
+            if owner := plainOwner(secret); owner != "" {
+                line += "  (owner: " + owner + ")"
+            }
+            fmt.Println(line)
+        }
+        os.Exit(expiringFound)
+    },
+}
+
+// plainOwner returns the owner recorded for a secret, or "" when it is
+// encrypted by the client.
+func plainOwner(secret storage.Secret) string {
+    if secret.Name != "" {
+        return ""
+    }
+    return secret.Owner
+}
+
+// shortID abbreviates an opaque lookup ID for display.
+func shortID(id string) string {
+    if len(id) > 12 {
+        return id[:12]
+    }
+    return id
+}
+
+func init() {
+    expiringCmd.Flags().String("within", "14d", "Also list secrets due within this long (e.g. 14d, 2w, 36h)")
+}
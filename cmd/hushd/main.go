@@ -1,21 +1,33 @@
 package main
 
 import (
+    "context"
+    "database/sql"
     "encoding/json"
+    "errors"
     "fmt"
     "log"
     "net/http"
+    "net/url"
     "os"
     "strings"
+    "time"
     
     "github.com/spf13/cobra"
+    "github.com/adith2005-20/hush/pkg/config"
     "github.com/adith2005-20/hush/pkg/storage"
 )
 
 type Server struct {
-    store *storage.Store
+    store storage.Backend
+    hub   *hub
+    wake  chan struct{}
 }
 
+type contextKey int
+
+const tokenKey contextKey = iota
+
 var rootCmd = &cobra.Command{
     Use:   "hushd",
     Short: "Hush secrets server",
@@ -25,21 +37,34 @@ var initCmd = &cobra.Command{
     Use:   "init",
     Short: "Initialize Hush server (first-time setup)",
     Run: func(cmd *cobra.Command, args []string) {
-        dbPath := getDBPath()
+        dsn := getDSN()
         
         // Check if already initialized
-        if _, err := os.Stat(dbPath); err == nil {
+        initialized, err := isInitialized(dsn)
+        if err != nil {
+            log.Fatal("Failed to open database:", err)
+        }
+        if initialized {
             fmt.Println("❌ Server already initialized!")
-            fmt.Printf("Database exists at: %s\n", dbPath)
-            fmt.Println("\nTo reinitialize, delete the database file first:")
-            fmt.Printf("  rm %s\n", dbPath)
+            if storage.IsPostgres(dsn) {
+                fmt.Printf("Database %s already has a Hush schema\n", describeDSN(dsn))
+            } else {
+                fmt.Printf("Database exists at: %s\n", dsn)
+                fmt.Println("\nTo reinitialize, delete the database file first:")
+                fmt.Printf("  rm %s\n", dsn)
+            }
             os.Exit(1)
         }
         
         fmt.Println("Initializing Hush server...")
         fmt.Println()
         
-        store, err := storage.New(dbPath)
+        key, err := loadServerKey()
+        if err != nil {
+            log.Fatal(err)
+        }
+
+        store, err := storage.New(dsn, storage.WithServerKey(key))
         if err != nil {
             log.Fatal("Failed to create database:", err)
         }
@@ -51,6 +76,9 @@ var initCmd = &cobra.Command{
         }
         
         fmt.Println("✓ Database created")
+        if key != nil {
+            fmt.Println("✓ Metadata encrypted with server key")
+        }
         fmt.Println("✓ Admin token generated")
         fmt.Println()
         fmt.Println("━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━")
@@ -73,30 +101,42 @@ var startCmd = &cobra.Command{
     Use:   "start",
     Short: "Start the Hush server",
     Run: func(cmd *cobra.Command, args []string) {
-        dbPath := getDBPath()
-        
-        // Check if initialized
-        if _, err := os.Stat(dbPath); os.IsNotExist(err) {
-            fmt.Println("❌ Server not initialized!")
-            fmt.Println("\nRun this first:")
-            fmt.Println("  hushd init")
-            os.Exit(1)
-        }
-        
-        store, err := storage.New(dbPath)
-        if err != nil {
-            log.Fatal(err)
-        }
+        dsn := getDSN()
+        store := openStore()
         defer store.Close()
 
-        server := &Server{store: store}
+        server := &Server{store: store, hub: newHub(), wake: make(chan struct{}, 1)}
+        go server.deliverWebhooks()
+
+        if dir, _ := cmd.Flags().GetString("backup-dir"); dir != "" {
+            if storage.IsPostgres(dsn) {
+                log.Fatal(storage.ErrBackupUnsupported)
+            }
+            every, _ := cmd.Flags().GetDuration("backup-every")
+            keep, _ := cmd.Flags().GetInt("backup-keep")
+            go scheduleBackups(store, dir, every, keep)
+            fmt.Printf("   Backups: every %s to %s (keeping %d)\n", every, dir, keep)
+        }
+
+        if warning, _ := cmd.Flags().GetString("expiry-warning"); warning != "0" {
+            window, err := config.ParseDuration(warning)
+            if err != nil {
+                log.Fatal(err)
+            }
+            go server.watchExpiry(window)
+        }
         
         http.HandleFunc("/health", server.handleHealth)
         http.HandleFunc("/api/secrets", server.authMiddleware(server.handleSecrets))
+        http.HandleFunc("/api/watch", server.authMiddleware(server.handleWatch))
+        http.HandleFunc("/api/groups", server.authMiddleware(server.handleGroups))
+        http.HandleFunc("/api/environments", server.authMiddleware(server.handleEnvironments))
+        http.HandleFunc("/api/expiring", server.authMiddleware(server.handleExpiring))
+        http.HandleFunc("/api/search", server.authMiddleware(server.handleSearch))
 
         port := getPort()
         fmt.Printf("🤫 Hush server listening on :%s\n", port)
-        fmt.Printf("   Database: %s\n", dbPath)
+        fmt.Printf("   Database: %s\n", describeDSN(dsn))
         log.Fatal(http.ListenAndServe(":"+port, nil))
     },
 }
@@ -114,20 +154,35 @@ func (s *Server) authMiddleware(next http.HandlerFunc) http.HandlerFunc {
             return
         }
 
-        token := strings.TrimPrefix(auth, "Bearer ")
-        if !s.store.ValidateToken(token) {
+        token, err := s.store.LookupToken(strings.TrimPrefix(auth, "Bearer "))
+        if err == sql.ErrNoRows {
             http.Error(w, "Invalid token", http.StatusUnauthorized)
             return
         }
+        if err != nil {
+            http.Error(w, err.Error(), http.StatusInternalServerError)
+            return
+        }
 
-        next(w, r)
+        next(w, r.WithContext(context.WithValue(r.Context(), tokenKey, token)))
     }
 }
 
+// actor names the token that made the request, for change events.
+func actor(r *http.Request) string {
+    if t, ok := r.Context().Value(tokenKey).(*storage.Token); ok {
+        return t.Name
+    }
+    return ""
+}
+
 func (s *Server) handleSecrets(w http.ResponseWriter, r *http.Request) {
-    if r.Method == "POST" {
+    switch r.Method {
+    case "POST":
         s.handleSetSecret(w, r)
-    } else {
+    case "DELETE":
+        s.handleDeleteSecret(w, r)
+    default:
         s.handleGetSecrets(w, r)
     }
 }
@@ -144,10 +199,37 @@ func (s *Server) handleSetSecret(w http.ResponseWriter, r *http.Request) {
         return
     }
 
+    s.publish(r, secret.Project, secret.Environment, secret.Key, storage.ActionUpsert)
+
     w.WriteHeader(http.StatusCreated)
     json.NewEncoder(w).Encode(map[string]string{"status": "ok"})
 }
 
+func (s *Server) handleDeleteSecret(w http.ResponseWriter, r *http.Request) {
+    project := r.URL.Query().Get("project")
+    env := r.URL.Query().Get("environment")
+    key := r.URL.Query().Get("key")
+
+    if project == "" || env == "" || key == "" {
+        http.Error(w, "project, environment and key required", http.StatusBadRequest)
+        return
+    }
+
+    deleted, err := s.store.DeleteSecret(project, env, key)
+    if err != nil {
+        http.Error(w, err.Error(), http.StatusInternalServerError)
+        return
+    }
+    if !deleted {
+        http.Error(w, "secret not found", http.StatusNotFound)
+        return
+    }
+
+    s.publish(r, project, env, key, storage.ActionDelete)
+
+    json.NewEncoder(w).Encode(map[string]string{"status": "ok"})
+}
+
 func (s *Server) handleGetSecrets(w http.ResponseWriter, r *http.Request) {
     project := r.URL.Query().Get("project")
     env := r.URL.Query().Get("environment")
@@ -157,7 +239,23 @@ func (s *Server) handleGetSecrets(w http.ResponseWriter, r *http.Request) {
         return
     }
 
-    secrets, err := s.store.GetSecrets(project, env)
+    // Secrets the environment does not set are read from the ones it
+    // inherits from. A key narrows the response to that one secret.
+    if key := r.URL.Query().Get("key"); key != "" {
+        secret, err := s.inheritedSecret(project, env, key)
+        if err == sql.ErrNoRows {
+            http.Error(w, "secret not found", http.StatusNotFound)
+            return
+        }
+        if err != nil {
+            http.Error(w, err.Error(), http.StatusInternalServerError)
+            return
+        }
+        json.NewEncoder(w).Encode(secret)
+        return
+    }
+
+    secrets, err := s.inheritedSecrets(project, env)
     if err != nil {
         http.Error(w, err.Error(), http.StatusInternalServerError)
         return
@@ -166,6 +264,105 @@ func (s *Server) handleGetSecrets(w http.ResponseWriter, r *http.Request) {
     json.NewEncoder(w).Encode(secrets)
 }
 
+// handleGroups lists the shared groups. A group's secrets are read and
+// written through /api/secrets under the project "@" + its name.
+func (s *Server) handleGroups(w http.ResponseWriter, r *http.Request) {
+    groups, err := s.store.ListGroups()
+    if err != nil {
+        http.Error(w, err.Error(), http.StatusInternalServerError)
+        return
+    }
+
+    json.NewEncoder(w).Encode(groups)
+}
+
+// getDSN selects the storage backend: HUSH_DATABASE_URL for PostgreSQL
+// (postgres://...), falling back to the SQLite file at HUSH_DB_PATH.
+func getDSN() string {
+    if dsn := os.Getenv("HUSH_DATABASE_URL"); dsn != "" {
+        return dsn
+    }
+    return getDBPath()
+}
+
+// describeDSN returns dsn with any password removed, for display.
+func describeDSN(dsn string) string {
+    if u, err := url.Parse(dsn); err == nil && storage.IsPostgres(dsn) {
+        return u.Redacted()
+    }
+    return dsn
+}
+
+// isInitialized reports whether hushd init has already run against dsn.
+func isInitialized(dsn string) (bool, error) {
+    if !storage.IsPostgres(dsn) {
+        _, err := os.Stat(dsn)
+        return err == nil, nil
+    }
+
+    store, err := storage.Open(dsn)
+    if err != nil {
+        return false, err
+    }
+    defer store.Close()
+
+    version, err := store.SchemaVersion()
+    return version > 0, err
+}
+
+// openStore opens and migrates the database, refusing to create a fresh
+// one by accident.
+func openStore() storage.Backend {
+    requireInitialized()
+    store, err := loadStore()
+    if err != nil {
+        log.Fatal(err)
+    }
+    return store
+}
+
+// loadStore is openStore for commands that report failures themselves.
+func loadStore() (storage.Backend, error) {
+    dsn := getDSN()
+    initialized, err := isInitialized(dsn)
+    if err != nil {
+        return nil, fmt.Errorf("failed to open database: %w", err)
+    }
+    if !initialized {
+        return nil, errors.New("server not initialized; run 'hushd init' first")
+    }
+    key, err := loadServerKey()
+    if err != nil {
+        return nil, err
+    }
+    return storage.New(dsn, storage.WithServerKey(key))
+}
+
+// openRawStore opens the database without applying migrations.
+func openRawStore() storage.Backend {
+    dsn := requireInitialized()
+    store, err := storage.Open(dsn)
+    if err != nil {
+        log.Fatal(err)
+    }
+    return store
+}
+
+func requireInitialized() string {
+    dsn := getDSN()
+    initialized, err := isInitialized(dsn)
+    if err != nil {
+        log.Fatal("Failed to open database:", err)
+    }
+    if !initialized {
+        fmt.Println("❌ Server not initialized!")
+        fmt.Println("\nRun this first:")
+        fmt.Println("  hushd init")
+        os.Exit(1)
+    }
+    return dsn
+}
+
 func getDBPath() string {
     if path := os.Getenv("HUSH_DB_PATH"); path != "" {
         return path
@@ -181,8 +378,20 @@ func getPort() string {
 }
 
 func main() {
+    rootCmd.PersistentFlags().String("server-key-file", "", "File holding the key that encrypts metadata at rest (or HUSH_SERVER_KEY_FILE / HUSH_SERVER_KEY)")
+    startCmd.Flags().String("backup-dir", "", "Write scheduled backups to this directory")
+    startCmd.Flags().Duration("backup-every", 24*time.Hour, "Interval between scheduled backups")
+    startCmd.Flags().Int("backup-keep", 7, "Number of scheduled backups to retain (0 keeps all)")
+    startCmd.Flags().String("expiry-warning", "14d", "Send secret.expiring webhooks this long before a secret is due (0 disables expiry webhooks)")
+
     rootCmd.AddCommand(initCmd)
     rootCmd.AddCommand(startCmd)
+    rootCmd.AddCommand(webhookCmd)
+    rootCmd.AddCommand(backupCmd)
+    rootCmd.AddCommand(restoreCmd)
+    rootCmd.AddCommand(migrateCmd)
+    rootCmd.AddCommand(rekeyCmd)
+    rootCmd.AddCommand(expiringCmd)
     
     if err := rootCmd.Execute(); err != nil {
         os.Exit(1)
@@ -0,0 +1,85 @@
+package main
+
+import (
+    "fmt"
+    "log"
+    "os"
+
+    "github.com/spf13/cobra"
+)
+
+var migrateCmd = &cobra.Command{
+    Use:   "migrate",
+    Short: "Inspect and apply database schema migrations",
+    Long: `Inspect and apply database schema migrations.
+
+'hushd start' applies pending migrations automatically; use these commands to
+check what would change or to upgrade a database ahead of time.`,
+}
+
+var migrateStatusCmd = &cobra.Command{
+    Use:   "status",
+    Short: "Show applied and pending migrations",
+    Run: func(cmd *cobra.Command, args []string) {
+        store := openRawStore()
+        defer store.Close()
+
+        states, err := store.MigrationStatus()
+        if err != nil {
+            log.Fatal(err)
+        }
+
+        pending, unknown := 0, 0
+        for _, m := range states {
+            switch {
+            case m.Name == "":
+                fmt.Printf("  ⚠️  %04d  (unknown to this build)  applied %s\n", m.Version, m.AppliedAt)
+                unknown++
+            case m.Applied():
+                fmt.Printf("  ✓ %04d  %s  applied %s\n", m.Version, m.Name, m.AppliedAt)
+            default:
+                fmt.Printf("  • %04d  %s  pending\n", m.Version, m.Name)
+                pending++
+            }
+        }
+
+        fmt.Println()
+        if unknown > 0 {
+            fmt.Println("❌ Database schema is newer than this build of hushd; upgrade hushd before starting it")
+            os.Exit(1)
+        }
+        if pending == 0 {
+            fmt.Println("Schema is up to date")
+        } else {
+            fmt.Printf("%d pending migration(s). Apply with:\n", pending)
+            fmt.Println("  hushd migrate up")
+        }
+    },
+}
+
+var migrateUpCmd = &cobra.Command{
+    Use:   "up",
+    Short: "Apply all pending migrations",
+    Run: func(cmd *cobra.Command, args []string) {
+        store := openRawStore()
+        defer store.Close()
+
+        applied, err := store.Migrate()
+        for _, m := range applied {
+            fmt.Printf("✓ Applied %04d_%s\n", m.Version, m.Name)
+        }
+        if err != nil {
+            fmt.Printf("❌ %v\n", err)
+            os.Exit(1)
+        }
+
+        if len(applied) == 0 {
+            fmt.Println("Schema is up to date")
+        }
+    },
+}
+
+func init() {
+    migrateCmd.AddCommand(migrateStatusCmd)
+    migrateCmd.AddCommand(migrateUpCmd)
+}
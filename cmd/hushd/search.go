@@ -0,0 +1,74 @@
+package main
+
+import (
+    "encoding/json"
+    "net/http"
+
+    "github.com/adith2005-20/hush/pkg/config"
+)
+
+// searchMatch is one secret found by /api/search.
+type searchMatch struct {
+    Project     string   `json:"project"`
+    Environment string   `json:"environment"`
+    Key         string   `json:"key"`
+    // Name is the client-encrypted key name of opaque-key secrets, which
+    // the server cannot match and returns for the client to check.
+    Name        string   `json:"name,omitempty"`
+    // Matched is the field the pattern matched, empty for opaque keys.
+    Matched     string   `json:"matched,omitempty"`
+    UpdatedAt   string   `json:"updated_at"`
+    Description string   `json:"description,omitempty"`
+    Owner       string   `json:"owner,omitempty"`
+    Tags        []string `json:"tags,omitempty"`
+    Link        string   `json:"link,omitempty"`
+    // Value is the encrypted value, only sent with ?values=1.
+    Value       string   `json:"value,omitempty"`
+}
+
+// handleSearch finds secrets in every project and environment whose key
+// name or metadata matches ?q=, a glob or, with ?regex=1, a regular
+// expression. ?opaque=1 adds every opaque-key secret for the client to
+// decrypt and match itself, and ?values=1 includes the encrypted values.
+func (s *Server) handleSearch(w http.ResponseWriter, r *http.Request) {
+    query := r.URL.Query()
+    pattern, err := config.CompilePattern(query.Get("q"), query.Get("regex") == "1")
+    if err != nil {
+        http.Error(w, err.Error(), http.StatusBadRequest)
+        return
+    }
+
+    secrets, err := s.store.AllSecrets()
+    if err != nil {
+        http.Error(w, err.Error(), http.StatusInternalServerError)
+        return
+    }
+
+    matches := []searchMatch{}
+    for _, secret := range secrets {
+        match := searchMatch{
+            Project:     secret.Project,
+            Environment: secret.Environment,
+            Key:         secret.Key,
+            Name:        secret.Name,
+            UpdatedAt:   secret.UpdatedAt,
+            Description: secret.Description,
+            Owner:       secret.Owner,
+            Tags:        secret.Tags,
+            Link:        secret.Link,
+        }
+        if secret.Name != "" {
+            if query.Get("opaque") != "1" {
+                continue
+            }
+        } else if match.Matched = pattern.MatchField(secret.Key, secret.Description, secret.Owner, secret.Tags, secret.Link); match.Matched == "" {
+            continue
+        }
+        if query.Get("values") == "1" {
+            match.Value = secret.Value
+        }
+        matches = append(matches, match)
+    }
+
+    json.NewEncoder(w).Encode(matches)
+}
@@ -0,0 +1,128 @@
+package main
+
+import (
+    "crypto/rand"
+    "encoding/base64"
+    "fmt"
+    "os"
+    "strings"
+
+    "github.com/spf13/cobra"
+    "github.com/adith2005-20/hush/pkg/storage"
+)
+
+// loadServerKey returns the key used to encrypt metadata at rest, or nil
+// when none is configured. The --server-key-file flag wins over
+// HUSH_SERVER_KEY_FILE, which wins over an inline base64 HUSH_SERVER_KEY.
+func loadServerKey() ([]byte, error) {
+    path, _ := rootCmd.PersistentFlags().GetString("server-key-file")
+    if path == "" {
+        path = os.Getenv("HUSH_SERVER_KEY_FILE")
+    }
+
+    if path != "" {
+        data, err := os.ReadFile(path)
+        if err != nil {
+            return nil, fmt.Errorf("failed to read server key: %w", err)
+        }
+        return parseServerKey(data)
+    }
+
+    if v := os.Getenv("HUSH_SERVER_KEY"); v != "" {
+        return parseServerKey([]byte(v))
+    }
+    return nil, nil
+}
+
+// parseServerKey accepts either raw key bytes or their base64 encoding.
+func parseServerKey(data []byte) ([]byte, error) {
+    if len(data) == storage.ServerKeySize {
+        return data, nil
+    }
+
+    key, err := base64.StdEncoding.DecodeString(strings.TrimSpace(string(data)))
+    if err != nil || len(key) != storage.ServerKeySize {
+        return nil, fmt.Errorf("server key must be %d bytes, raw or base64-encoded", storage.ServerKeySize)
+    }
+    return key, nil
+}
+
+var rekeyCmd = &cobra.Command{
+    Use:   "rekey",
+    Short: "Encrypt, re-encrypt or decrypt metadata with a server key",
+    Long: `Re-encrypt project, environment, key, token and webhook metadata at rest.
+
+The current key (if any) is loaded as usual from --server-key-file,
+HUSH_SERVER_KEY_FILE or HUSH_SERVER_KEY. Everything is rewritten in a single
+transaction; stop hushd first and restart it with the new key afterwards.
+
+Examples:
+  hushd rekey --generate --new-key-file /etc/hush/server.key   # encrypt or rotate
+  hushd rekey --new-key-file /etc/hush/server-2.key            # rotate to an existing key
+  hushd rekey --decrypt                                         # back to plaintext`,
+    Run: func(cmd *cobra.Command, args []string) {
+        newPath, _ := cmd.Flags().GetString("new-key-file")
+        generate, _ := cmd.Flags().GetBool("generate")
+        decrypt, _ := cmd.Flags().GetBool("decrypt")
+
+        if decrypt == (newPath != "") {
+            fmt.Println("❌ Specify either --new-key-file or --decrypt")
+            os.Exit(1)
+        }
+
+        var newKey []byte
+        if generate {
+            newKey = make([]byte, storage.ServerKeySize)
+            if _, err := rand.Read(newKey); err != nil {
+                fmt.Printf("❌ Failed to generate key: %v\n", err)
+                os.Exit(1)
+            }
+            f, err := os.OpenFile(newPath, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0600)
+            if err != nil {
+                fmt.Printf("❌ Failed to create key file: %v\n", err)
+                os.Exit(1)
+            }
+            _, err = f.WriteString(base64.StdEncoding.EncodeToString(newKey) + "\n")
+            if cerr := f.Close(); err == nil {
+                err = cerr
+            }
+            if err != nil {
+                fmt.Printf("❌ Failed to write key file: %v\n", err)
+                os.Exit(1)
+            }
+            fmt.Printf("✓ Generated new server key at %s\n", newPath)
+        } else if newPath != "" {
+            data, err := os.ReadFile(newPath)
+            if err != nil {
+                fmt.Printf("❌ Failed to read new key: %v\n", err)
+                os.Exit(1)
+            }
+            if newKey, err = parseServerKey(data); err != nil {
+                fmt.Printf("❌ %v\n", err)
+                os.Exit(1)
+            }
+        }
+
+        store := openStore()
+        defer store.Close()
+
+        if err := store.Rekey(newKey); err != nil {
+            fmt.Printf("❌ Rekey failed, nothing was changed: %v\n", err)
+            os.Exit(1)
+        }
+
+        if newKey == nil {
+            fmt.Println("✓ Metadata decrypted; start hushd without a server key")
+            return
+        }
+        fmt.Println("✓ Metadata re-encrypted")
+        fmt.Println("\nStart the server with the new key:")
+        fmt.Printf("  HUSH_SERVER_KEY_FILE=%s hushd start\n", newPath)
+    },
+}
+
+func init() {
+    rekeyCmd.Flags().String("new-key-file", "", "Key to encrypt with (base64 or raw 32 bytes)")
+    rekeyCmd.Flags().Bool("generate", false, "Generate a new random key and write it to --new-key-file")
+    rekeyCmd.Flags().Bool("decrypt", false, "Remove encryption and store metadata in plaintext")
+}
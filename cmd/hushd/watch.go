@@ -0,0 +1,222 @@
+package main
+
+import (
+    "encoding/json"
+    "fmt"
+    "log"
+    "net/http"
+    "sort"
+    "strconv"
+    "sync"
+    "time"
+
+    "github.com/adith2005-20/hush/pkg/storage"
+)
+
+// heartbeatInterval keeps idle watch connections from being reaped by proxies.
+const heartbeatInterval = 25 * time.Second
+
+// hub fans change events out to the watchers of a project/environment.
+type hub struct {
+    mu   sync.Mutex
+    subs map[string]map[chan storage.Event]struct{}
+}
+
+func newHub() *hub {
+    return &hub{subs: make(map[string]map[chan storage.Event]struct{})}
+}
+
+func hubKey(project, env string) string {
+    return project + "\x00" + env
+}
+
+func (h *hub) subscribe(project, env string) chan storage.Event {
+    h.mu.Lock()
+    defer h.mu.Unlock()
+
+    ch := make(chan storage.Event, 16)
+    key := hubKey(project, env)
+    if h.subs[key] == nil {
+        h.subs[key] = make(map[chan storage.Event]struct{})
+    }
+    h.subs[key][ch] = struct{}{}
+    return ch
+}
+
+func (h *hub) unsubscribe(project, env string, ch chan storage.Event) {
+    h.mu.Lock()
+    defer h.mu.Unlock()
+    h.remove(hubKey(project, env), ch)
+}
+
+// remove must be called with h.mu held.
+func (h *hub) remove(key string, ch chan storage.Event) {
+    if _, ok := h.subs[key][ch]; !ok {
+        return
+    }
+    delete(h.subs[key], ch)
+    close(ch)
+    if len(h.subs[key]) == 0 {
+        delete(h.subs, key)
+    }
+}
+
+// broadcast never blocks: a watcher that has fallen behind is disconnected
+// and is expected to reconnect with Last-Event-ID to replay what it missed.
+func (h *hub) broadcast(e storage.Event) {
+    h.mu.Lock()
+    defer h.mu.Unlock()
+
+    key := hubKey(e.Project, e.Environment)
+    for ch := range h.subs[key] {
+        select {
+        case ch <- e:
+        default:
+            h.remove(key, ch)
+        }
+    }
+}
+
+// publish records a change event and notifies watchers and webhooks.
+func (s *Server) publish(r *http.Request, project, env, key, action string) {
+    e := storage.Event{
+        Project:     project,
+        Environment: env,
+        Key:         key,
+        Action:      action,
+        Actor:       actor(r),
+    }
+    if err := s.store.AppendEvent(&e); err != nil {
+        log.Printf("failed to record %s event for %s/%s/%s: %v", action, project, env, key, err)
+        return
+    }
+    s.hub.broadcast(e)
+    s.enqueueWebhooks(e)
+}
+
+func (s *Server) handleWatch(w http.ResponseWriter, r *http.Request) {
+    project := r.URL.Query().Get("project")
+    env := r.URL.Query().Get("environment")
+
+    if project == "" || env == "" {
+        http.Error(w, "project and environment required", http.StatusBadRequest)
+        return
+    }
+
+    var last int64
+    if id := r.Header.Get("Last-Event-ID"); id != "" {
+        var err error
+        if last, err = strconv.ParseInt(id, 10, 64); err != nil {
+            http.Error(w, "invalid Last-Event-ID", http.StatusBadRequest)
+            return
+        }
+    }
+
+    flusher, ok := w.(http.Flusher)
+    if !ok {
+        http.Error(w, "streaming unsupported", http.StatusInternalServerError)
+        return
+    }
+
+    // Changes to the environments env inherits from change what it reads,
+    // so they are streamed too.
+    chain, err := s.environmentChain(project, env)
+    if err != nil {
+        http.Error(w, err.Error(), http.StatusInternalServerError)
+        return
+    }
+
+    // Subscribe before replaying so nothing committed in between is lost.
+    ch, lost := make(chan storage.Event), make(chan struct{}, len(chain))
+    done := make(chan struct{})
+    defer close(done)
+    for _, e := range chain {
+        sub := s.hub.subscribe(project, e)
+        defer s.hub.unsubscribe(project, e, sub)
+        go forwardEvents(sub, ch, lost, done)
+    }
+
+    var backlog []storage.Event
+    if last > 0 {
+        for _, e := range chain {
+            events, err := s.store.EventsSince(project, e, last)
+            if err != nil {
+                http.Error(w, err.Error(), http.StatusInternalServerError)
+                return
+            }
+            backlog = append(backlog, events...)
+        }
+        sort.Slice(backlog, func(i, j int) bool { return backlog[i].ID < backlog[j].ID })
+    }
+
+    w.Header().Set("Content-Type", "text/event-stream")
+    w.Header().Set("Cache-Control", "no-cache")
+    w.Header().Set("Connection", "keep-alive")
+    w.WriteHeader(http.StatusOK)
+    flusher.Flush()
+
+    for _, e := range backlog {
+        if err := writeEvent(w, e); err != nil {
+            return
+        }
+        last = e.ID
+    }
+    // A fresh subscriber has nothing to replay from; tell it when it is
+    // subscribed so it can catch up on changes made before then.
+    if last == 0 && r.URL.Query().Get("ready") == "1" {
+        data, _ := json.Marshal(storage.Event{Project: project, Environment: env, Action: storage.ActionReady})
+        if _, err := fmt.Fprintf(w, "event: %s\ndata: %s\n\n", storage.ActionReady, data); err != nil {
+            return
+        }
+    }
+    flusher.Flush()
+
+    heartbeat := time.NewTicker(heartbeatInterval)
+    defer heartbeat.Stop()
+
+    for {
+        select {
+        case <-r.Context().Done():
+            return
+        case <-heartbeat.C:
+            if _, err := fmt.Fprint(w, ": ping\n\n"); err != nil {
+                return
+            }
+            flusher.Flush()
+        case <-lost:
+            return
+        case e := <-ch:
+            if e.ID <= last {
+                continue
+            }
+            if err := writeEvent(w, e); err != nil {
+                return
+            }
+            last = e.ID
+            flusher.Flush()
+        }
+    }
+}
+
+// forwardEvents copies events from one subscription to out until done. When
+// the hub drops the subscription, it signals lost so the watcher
+// disconnects and the client replays what it missed.
+func forwardEvents(sub <-chan storage.Event, out chan<- storage.Event, lost chan<- struct{}, done <-chan struct{}) {
+    for e := range sub {
+        select {
+        case out <- e:
+        case <-done:
+            return
+        }
+    }
+    lost <- struct{}{}
+}
+
+func writeEvent(w http.ResponseWriter, e storage.Event) error {
+    data, err := json.Marshal(e)
+    if err != nil {
+        return err
+    }
+    _, err = fmt.Fprintf(w, "id: %d\nevent: %s\ndata: %s\n\n", e.ID, e.Action, data)
+    return err
+}
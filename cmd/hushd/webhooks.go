@@ -0,0 +1,410 @@
+package main
+
+import (
+    "bytes"
+    "crypto/hmac"
+    "crypto/rand"
+    "crypto/sha256"
+    "database/sql"
+    "encoding/hex"
+    "encoding/json"
+    "fmt"
+    "io"
+    "log"
+    "net/http"
+    "os"
+    "slices"
+    "strconv"
+    "strings"
+    "time"
+
+    "github.com/google/uuid"
+    "github.com/spf13/cobra"
+    "github.com/adith2005-20/hush/pkg/storage"
+)
+
+// Webhook event names. Subscriptions may also use "*" for every event.
+const (
+    EventSecretUpdated  = "secret.updated"
+    EventSecretDeleted  = "secret.deleted"
+    EventSecretExpiring = "secret.expiring"
+    EventSecretExpired  = "secret.expired"
+    EventPing           = "ping"
+)
+
+const (
+    maxDeliveryAttempts  = 8
+    deliveryPollInterval = 5 * time.Second
+    deliveryTimeout      = 10 * time.Second
+)
+
+var webhookEvents = map[string]string{
+    storage.ActionUpsert: EventSecretUpdated,
+    storage.ActionDelete: EventSecretDeleted,
+}
+
+// webhookPayload is what subscribers receive. It deliberately carries only
+// metadata about the change, never the (encrypted) value.
+type webhookPayload struct {
+    ID          string `json:"id"`
+    Event       string `json:"event"`
+    Project     string `json:"project,omitempty"`
+    Environment string `json:"environment,omitempty"`
+    Key         string `json:"key,omitempty"`
+    Revision    int64  `json:"revision,omitempty"`
+    Actor       string `json:"actor,omitempty"`
+    // DueAt is when the secret expires or is due for rotation, and Owner
+    // who looks after it, for expiry events.
+    DueAt       string `json:"due_at,omitempty"`
+    Owner       string `json:"owner,omitempty"`
+    Timestamp   string `json:"timestamp"`
+}
+
+// enqueueWebhooks queues deliveries for a change event and wakes the worker.
+func (s *Server) enqueueWebhooks(e storage.Event) {
+    s.queueWebhook(webhookPayload{
+        Event:       webhookEvents[e.Action],
+        Project:     e.Project,
+        Environment: e.Environment,
+        Key:         e.Key,
+        Revision:    e.ID,
+        Actor:       e.Actor,
+    })
+}
+
+// queueWebhook stamps p and queues it for every matching subscription,
+// reporting whether that succeeded.
+func (s *Server) queueWebhook(p webhookPayload) bool {
+    p.ID = uuid.New().String()
+    p.Timestamp = time.Now().UTC().Format(time.RFC3339)
+    payload, err := json.Marshal(p)
+    if err != nil {
+        log.Printf("failed to encode webhook payload: %v", err)
+        return false
+    }
+
+    n, err := s.store.EnqueueDeliveries(p.Event, p.Project, p.Environment, string(payload))
+    if err != nil {
+        log.Printf("failed to queue webhooks for %s/%s/%s: %v", p.Project, p.Environment, p.Key, err)
+        return false
+    }
+    if n > 0 {
+        select {
+        case s.wake <- struct{}{}:
+        default:
+        }
+    }
+    return true
+}
+
+// deliverWebhooks sends due deliveries until the process exits, retrying
+// failures with exponential backoff before dead-lettering them.
+func (s *Server) deliverWebhooks() {
+    ticker := time.NewTicker(deliveryPollInterval)
+    defer ticker.Stop()
+
+    for {
+        due, err := s.store.DueDeliveries(time.Now(), 50)
+        if err != nil {
+            log.Printf("failed to load webhook deliveries: %v", err)
+        }
+
+        for _, d := range due {
+            err := sendWebhook(d.URL, d.Secret, d.Event, d.Payload)
+            if err == nil {
+                if err := s.store.MarkDelivered(d.ID); err != nil {
+                    log.Printf("failed to mark delivery %d: %v", d.ID, err)
+                }
+                continue
+            }
+
+            var next time.Time
+            if d.Attempts+1 < maxDeliveryAttempts {
+                next = time.Now().Add(retryBackoff(d.Attempts + 1))
+            } else {
+                log.Printf("webhook delivery %d to %s dead-lettered: %v", d.ID, d.URL, err)
+            }
+            if err := s.store.MarkFailed(d.ID, err.Error(), next); err != nil {
+                log.Printf("failed to mark delivery %d: %v", d.ID, err)
+            }
+        }
+
+        select {
+        case <-ticker.C:
+        case <-s.wake:
+        }
+    }
+}
+
+// retryBackoff returns the delay before the given attempt: 10s, 20s, 40s...
+// capped at an hour.
+func retryBackoff(attempt int) time.Duration {
+    d := 10 * time.Second << (attempt - 1)
+    if d > time.Hour || d <= 0 {
+        return time.Hour
+    }
+    return d
+}
+
+// sendWebhook POSTs payload to url. The body is signed with HMAC-SHA256 over
+// "<timestamp>.<body>" so receivers can verify origin and reject replays.
+func sendWebhook(url, secret, event, payload string) error {
+    timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+
+    req, err := http.NewRequest("POST", url, bytes.NewBufferString(payload))
+    if err != nil {
+        return err
+    }
+    req.Header.Set("Content-Type", "application/json")
+    req.Header.Set("User-Agent", "hushd-webhook")
+    req.Header.Set("X-Hush-Event", event)
+    req.Header.Set("X-Hush-Timestamp", timestamp)
+    req.Header.Set("X-Hush-Signature", "sha256="+signPayload(secret, timestamp, payload))
+
+    client := &http.Client{Timeout: deliveryTimeout}
+    resp, err := client.Do(req)
+    if err != nil {
+        return err
+    }
+    defer resp.Body.Close()
+    io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+
+    if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+        return fmt.Errorf("receiver returned %s", resp.Status)
+    }
+    return nil
+}
+
+func signPayload(secret, timestamp, payload string) string {
+    mac := hmac.New(sha256.New, []byte(secret))
+    mac.Write([]byte(timestamp + "." + payload))
+    return hex.EncodeToString(mac.Sum(nil))
+}
+
+func newWebhookSecret() (string, error) {
+    b := make([]byte, 24)
+    if _, err := rand.Read(b); err != nil {
+        return "", err
+    }
+    return "whsec_" + hex.EncodeToString(b), nil
+}
+
+func parseWebhookID(arg string) int64 {
+    id, err := strconv.ParseInt(arg, 10, 64)
+    if err != nil {
+        fmt.Printf("❌ Invalid webhook id: %s\n", arg)
+        os.Exit(1)
+    }
+    return id
+}
+
+func orAny(s string) string {
+    if s == "" {
+        return "*"
+    }
+    return s
+}
+
+var webhookCmd = &cobra.Command{
+    Use:   "webhook",
+    Short: "Manage outbound webhooks for secret changes",
+}
+
+var webhookAddCmd = &cobra.Command{
+    Use:   "add URL",
+    Short: "Subscribe a URL to secret change events",
+    Long: `Subscribe a URL to secret change events.
+
+Payloads contain metadata only (project, environment, key, revision, actor),
+never secret values. secret.expiring and secret.expired fire once per
+secret as it comes within --expiry-warning of its due date and passes it. Each request is signed: verify X-Hush-Signature as
+"sha256=" + hex(HMAC-SHA256(secret, X-Hush-Timestamp + "." + body)).
+
+Examples:
+  hushd webhook add https://hooks.slack.com/... --project api --env production
+  hushd webhook add https://ci.example.com/hook --events secret.updated`,
+    Args: cobra.ExactArgs(1),
+    Run: func(cmd *cobra.Command, args []string) {
+        project, _ := cmd.Flags().GetString("project")
+        env, _ := cmd.Flags().GetString("env")
+        events, _ := cmd.Flags().GetStringSlice("events")
+
+        valid := []string{"*", EventSecretUpdated, EventSecretDeleted, EventSecretExpiring, EventSecretExpired}
+        for _, e := range events {
+            if !slices.Contains(valid, e) {
+                fmt.Printf("❌ Unknown event type: %s\n", e)
+                fmt.Printf("Valid events: %s\n", strings.Join(valid, ", "))
+                os.Exit(1)
+            }
+        }
+
+        secret, err := newWebhookSecret()
+        if err != nil {
+            log.Fatal("Failed to generate signing secret:", err)
+        }
+
+        store := openStore()
+        defer store.Close()
+
+        hook := &storage.Webhook{
+            URL:         args[0],
+            Secret:      secret,
+            Project:     project,
+            Environment: env,
+            Events:      events,
+        }
+        if err := store.CreateWebhook(hook); err != nil {
+            log.Fatal("Failed to create webhook:", err)
+        }
+
+        fmt.Printf("✓ Webhook %d created\n", hook.ID)
+        fmt.Println()
+        fmt.Println("🔑 Signing secret (save this, it is used to verify X-Hush-Signature):")
+        fmt.Printf("   %s\n", secret)
+    },
+}
+
+var webhookListCmd = &cobra.Command{
+    Use:   "list",
+    Short: "List webhook subscriptions",
+    Run: func(cmd *cobra.Command, args []string) {
+        store := openStore()
+        defer store.Close()
+
+        hooks, err := store.ListWebhooks()
+        if err != nil {
+            log.Fatal(err)
+        }
+
+        if len(hooks) == 0 {
+            fmt.Println("No webhooks configured")
+            return
+        }
+
+        for _, w := range hooks {
+            fmt.Printf("  %d  %s\n", w.ID, w.URL)
+            fmt.Printf("      %s/%s  events: %s\n", orAny(w.Project), orAny(w.Environment), strings.Join(w.Events, ","))
+        }
+    },
+}
+
+var webhookRemoveCmd = &cobra.Command{
+    Use:   "remove ID",
+    Short: "Delete a webhook subscription",
+    Args:  cobra.ExactArgs(1),
+    Run: func(cmd *cobra.Command, args []string) {
+        id := parseWebhookID(args[0])
+
+        store := openStore()
+        defer store.Close()
+
+        removed, err := store.DeleteWebhook(id)
+        if err != nil {
+            log.Fatal(err)
+        }
+        if !removed {
+            fmt.Printf("❌ No webhook with id %d\n", id)
+            os.Exit(1)
+        }
+
+        fmt.Printf("✓ Removed webhook %d\n", id)
+    },
+}
+
+var webhookTestCmd = &cobra.Command{
+    Use:   "test ID",
+    Short: "Send a signed ping event to a webhook",
+    Args:  cobra.ExactArgs(1),
+    Run: func(cmd *cobra.Command, args []string) {
+        id := parseWebhookID(args[0])
+
+        store := openStore()
+        defer store.Close()
+
+        hook, err := store.GetWebhook(id)
+        if err == sql.ErrNoRows {
+            fmt.Printf("❌ No webhook with id %d\n", id)
+            os.Exit(1)
+        }
+        if err != nil {
+            log.Fatal(err)
+        }
+
+        payload, _ := json.Marshal(webhookPayload{
+            ID:          uuid.New().String(),
+            Event:       EventPing,
+            Project:     hook.Project,
+            Environment: hook.Environment,
+            Timestamp:   time.Now().UTC().Format(time.RFC3339),
+        })
+
+        fmt.Printf("📡 Sending ping to %s...\n", hook.URL)
+        if err := sendWebhook(hook.URL, hook.Secret, EventPing, string(payload)); err != nil {
+            fmt.Printf("❌ Delivery failed: %v\n", err)
+            os.Exit(1)
+        }
+        fmt.Println("✓ Delivered")
+    },
+}
+
+var webhookDeadCmd = &cobra.Command{
+    Use:   "dead-letters",
+    Short: "List deliveries that exhausted their retries",
+    Run: func(cmd *cobra.Command, args []string) {
+        store := openStore()
+        defer store.Close()
+
+        dead, err := store.DeadDeliveries()
+        if err != nil {
+            log.Fatal(err)
+        }
+
+        if len(dead) == 0 {
+            fmt.Println("No dead-lettered deliveries")
+            return
+        }
+
+        for _, d := range dead {
+            fmt.Printf("  %d  webhook %d  %s  %s\n", d.ID, d.WebhookID, d.Event, d.CreatedAt)
+            fmt.Printf("      %d attempts, last error: %s\n", d.Attempts, d.LastError)
+        }
+        fmt.Println("\nRequeue with:")
+        fmt.Println("  hushd webhook retry DELIVERY_ID")
+    },
+}
+
+var webhookRetryCmd = &cobra.Command{
+    Use:   "retry DELIVERY_ID",
+    Short: "Requeue a dead-lettered delivery",
+    Args:  cobra.ExactArgs(1),
+    Run: func(cmd *cobra.Command, args []string) {
+        id := parseWebhookID(args[0])
+
+        store := openStore()
+        defer store.Close()
+
+        ok, err := store.RetryDelivery(id)
+        if err != nil {
+            log.Fatal(err)
+        }
+        if !ok {
+            fmt.Printf("❌ No dead-lettered delivery with id %d\n", id)
+            os.Exit(1)
+        }
+
+        fmt.Printf("✓ Delivery %d requeued (a running hushd will send it shortly)\n", id)
+    },
+}
+
+func init() {
+    webhookAddCmd.Flags().String("project", "", "Only fire for this project (default: all)")
+    webhookAddCmd.Flags().String("env", "", "Only fire for this environment (default: all)")
+    webhookAddCmd.Flags().StringSlice("events", []string{"*"}, "Event types to deliver")
+
+    webhookCmd.AddCommand(webhookAddCmd)
+    webhookCmd.AddCommand(webhookListCmd)
+    webhookCmd.AddCommand(webhookRemoveCmd)
+    webhookCmd.AddCommand(webhookTestCmd)
+    webhookCmd.AddCommand(webhookDeadCmd)
+    webhookCmd.AddCommand(webhookRetryCmd)
+}
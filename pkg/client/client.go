@@ -7,9 +7,48 @@ import (
 	"fmt"
 	"io"
 	"net/http"
+	"net/url"
+	"strings"
 	"time"
 )
 
+var (
+	// ErrUnauthorized means the server rejected the token.
+	ErrUnauthorized = errors.New("unauthorized: check your token or run 'hush login'")
+	// ErrForbidden means the token is valid but may not access the
+	// project or environment.
+	ErrForbidden = errors.New("forbidden")
+	// ErrNotFound means the requested secret does not exist.
+	ErrNotFound = errors.New("not found")
+	// ErrConflict means the server refused a change that clashes with
+	// existing state.
+	ErrConflict = errors.New("conflict")
+)
+
+// checkResponse turns an unsuccessful response into an error that wraps
+// ErrUnauthorized, ErrForbidden, ErrNotFound or ErrConflict where one
+// applies.
+func checkResponse(resp *http.Response) error {
+	switch resp.StatusCode {
+	case http.StatusOK, http.StatusCreated:
+		return nil
+	case http.StatusUnauthorized:
+		return ErrUnauthorized
+	}
+
+	body, _ := io.ReadAll(resp.Body)
+	msg := strings.TrimSpace(string(body))
+	switch resp.StatusCode {
+	case http.StatusForbidden:
+		return fmt.Errorf("%s: %w", msg, ErrForbidden)
+	case http.StatusNotFound:
+		return fmt.Errorf("%s: %w", msg, ErrNotFound)
+	case http.StatusConflict:
+		return fmt.Errorf("%s: %w", msg, ErrConflict)
+	}
+	return fmt.Errorf("server returned %s: %s", resp.Status, msg)
+}
+
 type Client struct {
 	BaseURL string
 	Token   string
@@ -21,6 +60,29 @@ type Secret struct {
 	Project   string `json:"project"`
 	Env       string `json:"environment"`
 	UpdatedAt string `json:"updated_at"`
+	// Name is the encrypted key name for opaque-key projects, where Key is
+	// only a lookup ID.
+	Name string `json:"name,omitempty"`
+	// File and FileMode mark a file secret, which pull writes to a file of
+	// that name and permissions instead of inlining it. File is encrypted
+	// like Name for opaque-key projects.
+	File     string `json:"file,omitempty"`
+	FileMode uint32 `json:"file_mode,omitempty"`
+	// ExpiresAt (UTC, "YYYY-MM-DD HH:MM:SS") and RotateEvery (seconds after
+	// each update) say when the value is due for replacement. DueAt, the
+	// earlier of the two, is filled in by the server.
+	ExpiresAt   string `json:"expires_at,omitempty"`
+	RotateEvery int64  `json:"rotate_every,omitempty"`
+	DueAt       string `json:"due_at,omitempty"`
+	// InheritedFrom names the parent environment a secret was read from,
+	// and is empty for secrets set in the requested environment.
+	InheritedFrom string `json:"inherited_from,omitempty"`
+	// Description, Owner, Tags and Link are free-form notes about the
+	// secret. Like File, they are encrypted for opaque-key projects.
+	Description string   `json:"description,omitempty"`
+	Owner       string   `json:"owner,omitempty"`
+	Tags        []string `json:"tags,omitempty"`
+	Link        string   `json:"link,omitempty"`
 }
 
 func New(baseURL, token string) *Client {
@@ -31,13 +93,17 @@ func New(baseURL, token string) *Client {
 }
 
 func (c *Client) SetSecret(project, env, key, encryptedvalue string) error {
-	secret := Secret{
-		key,
-		encryptedvalue,
-		project,
-		env,
-		time.Now().Local().String(),
-	}
+	return c.PutSecret(Secret{
+		Key:     key,
+		Value:   encryptedvalue,
+		Project: project,
+		Env:     env,
+	})
+}
+
+// PutSecret creates or updates a secret, including any encrypted name.
+func (c *Client) PutSecret(secret Secret) error {
+	secret.UpdatedAt = time.Now().Local().String()
 
 	body, _ := json.Marshal(secret)
 	req, err := http.NewRequest("POST", c.BaseURL+"/api/secrets", bytes.NewBuffer(body))
@@ -55,12 +121,7 @@ func (c *Client) SetSecret(project, env, key, encryptedvalue string) error {
 
 	defer resp.Body.Close()
 
-	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusCreated {
-		body, _ := io.ReadAll(resp.Body)
-		return errors.New("Invalid response from server" + string(body))
-	}
-
-	return nil
+	return checkResponse(resp)
 }
 
 func (c *Client) GetSecrets(project, env string) ([]Secret, error) {
@@ -78,8 +139,8 @@ func (c *Client) GetSecrets(project, env string) ([]Secret, error) {
 	}
 	defer resp.Body.Close()
 
-	if resp.StatusCode != http.StatusOK {
-		return nil, errors.New("server returned a malformed response")
+	if err := checkResponse(resp); err != nil {
+		return nil, err
 	}
 
 	var secrets []Secret
@@ -91,6 +152,64 @@ func (c *Client) GetSecrets(project, env string) ([]Secret, error) {
 	return secrets, nil
 }
 
+// GetSecret fetches a single secret. It returns an error wrapping
+// ErrNotFound if the key is not set and ErrUnauthorized if the token is
+// rejected.
+func (c *Client) GetSecret(project, env, key string) (*Secret, error) {
+	q := url.Values{"project": {project}, "environment": {env}, "key": {key}}
+	req, err := http.NewRequest("GET", c.BaseURL+"/api/secrets?"+q.Encode(), nil)
+	if err != nil {
+		return nil, err
+	}
+
+	req.Header.Set("Authorization", "Bearer "+c.Token)
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, fmt.Errorf("secret %s %w", key, ErrNotFound)
+	}
+	if err := checkResponse(resp); err != nil {
+		return nil, err
+	}
+
+	var secret Secret
+	if err := json.NewDecoder(resp.Body).Decode(&secret); err != nil {
+		return nil, err
+	}
+
+	return &secret, nil
+}
+
+func (c *Client) DeleteSecret(project, env, key string) error {
+	q := url.Values{"project": {project}, "environment": {env}, "key": {key}}
+	req, err := http.NewRequest("DELETE", c.BaseURL+"/api/secrets?"+q.Encode(), nil)
+	if err != nil {
+		return err
+	}
+
+	req.Header.Set("Authorization", "Bearer "+c.Token)
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound {
+		return fmt.Errorf("secret %s %w", key, ErrNotFound)
+	}
+	if err := checkResponse(resp); err != nil {
+		return err
+	}
+
+	return nil
+}
+
 func (c *Client) ListProjects() ([]string, error) {
 	req, err := http.NewRequest("GET", c.BaseURL+"/api/projects", nil)
 	if err != nil {
@@ -105,6 +224,10 @@ func (c *Client) ListProjects() ([]string, error) {
 	}
 	defer resp.Body.Close()
 
+	if err := checkResponse(resp); err != nil {
+		return nil, err
+	}
+
 	var projects []string
 
 	if err := json.NewDecoder(resp.Body).Decode(&projects); err != nil {
@@ -114,6 +237,143 @@ func (c *Client) ListProjects() ([]string, error) {
 	return projects, nil
 }
 
+// GetParent returns the environment env inherits secrets from, or "".
+func (c *Client) GetParent(project, env string) (string, error) {
+	q := url.Values{"project": {project}, "environment": {env}}
+	req, err := http.NewRequest("GET", c.BaseURL+"/api/environments?"+q.Encode(), nil)
+	if err != nil {
+		return "", err
+	}
+
+	req.Header.Set("Authorization", "Bearer "+c.Token)
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	if err := checkResponse(resp); err != nil {
+		return "", err
+	}
+
+	var result struct {
+		Parent string `json:"parent"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return "", err
+	}
+
+	return result.Parent, nil
+}
+
+// SetParent makes env inherit the secrets it does not set from parent, or
+// stops it inheriting when parent is "". It returns an error wrapping
+// ErrConflict if parent already inherits from env.
+func (c *Client) SetParent(project, env, parent string) error {
+	body, _ := json.Marshal(map[string]string{"project": project, "environment": env, "parent": parent})
+	req, err := http.NewRequest("POST", c.BaseURL+"/api/environments", bytes.NewBuffer(body))
+	if err != nil {
+		return err
+	}
+
+	req.Header.Set("Authorization", "Bearer "+c.Token)
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	return checkResponse(resp)
+}
+
+// ListGroups returns the names of the shared groups on the server.
+func (c *Client) ListGroups() ([]string, error) {
+	req, err := http.NewRequest("GET", c.BaseURL+"/api/groups", nil)
+	if err != nil {
+		return nil, err
+	}
+
+	req.Header.Set("Authorization", "Bearer "+c.Token)
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if err := checkResponse(resp); err != nil {
+		return nil, err
+	}
+
+	var groups []string
+	if err := json.NewDecoder(resp.Body).Decode(&groups); err != nil {
+		return nil, err
+	}
+
+	return groups, nil
+}
+
+// SearchResult is a secret found by Search. Key names and metadata are
+// encrypted for opaque-key secrets, as in Secret.
+type SearchResult struct {
+	Project     string   `json:"project"`
+	Env         string   `json:"environment"`
+	Key         string   `json:"key"`
+	Name        string   `json:"name,omitempty"`
+	Matched     string   `json:"matched,omitempty"`
+	UpdatedAt   string   `json:"updated_at"`
+	Description string   `json:"description,omitempty"`
+	Owner       string   `json:"owner,omitempty"`
+	Tags        []string `json:"tags,omitempty"`
+	Link        string   `json:"link,omitempty"`
+	Value       string   `json:"value,omitempty"`
+}
+
+// SearchOptions adjust Search. Opaque asks for every opaque-key secret,
+// which the server cannot match, and Values for encrypted values.
+type SearchOptions struct {
+	Regex  bool
+	Opaque bool
+	Values bool
+}
+
+// Search finds secrets in every project and environment whose key name or
+// metadata matches pattern.
+func (c *Client) Search(pattern string, opts SearchOptions) ([]SearchResult, error) {
+	q := url.Values{"q": {pattern}}
+	for name, set := range map[string]bool{"regex": opts.Regex, "opaque": opts.Opaque, "values": opts.Values} {
+		if set {
+			q.Set(name, "1")
+		}
+	}
+	req, err := http.NewRequest("GET", c.BaseURL+"/api/search?"+q.Encode(), nil)
+	if err != nil {
+		return nil, err
+	}
+
+	req.Header.Set("Authorization", "Bearer "+c.Token)
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if err := checkResponse(resp); err != nil {
+		return nil, err
+	}
+
+	var results []SearchResult
+	if err := json.NewDecoder(resp.Body).Decode(&results); err != nil {
+		return nil, err
+	}
+
+	return results, nil
+}
+
 func (c *Client) Ping() error {
     resp, err := http.Get(c.BaseURL + "/health")
     if err != nil {
@@ -0,0 +1,88 @@
+package client
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// Event describes a change to a secret as reported by the server.
+type Event struct {
+	Revision    int64  `json:"revision"`
+	Project     string `json:"project"`
+	Environment string `json:"environment"`
+	Key         string `json:"key"`
+	Action      string `json:"action"`
+	Actor       string `json:"actor"`
+	CreatedAt   string `json:"created_at"`
+}
+
+// ActionReady is the action of the event Watch delivers once a stream
+// opened without a revision is subscribed. Anything changed before it
+// arrived is not replayed, so callers should re-read the secrets then.
+const ActionReady = "ready"
+
+// Watch streams change events for project/env, calling handle for each one
+// until ctx is cancelled, the server closes the stream, or handle returns an
+// error. Events after revision since are replayed first, so callers can
+// reconnect with the last revision they saw without missing changes; with
+// since 0 the stream starts with an ActionReady event instead.
+func (c *Client) Watch(ctx context.Context, project, env string, since int64, handle func(Event) error) error {
+	q := url.Values{"project": {project}, "environment": {env}, "ready": {"1"}}
+	req, err := http.NewRequestWithContext(ctx, "GET", c.BaseURL+"/api/watch?"+q.Encode(), nil)
+	if err != nil {
+		return err
+	}
+
+	req.Header.Set("Authorization", "Bearer "+c.Token)
+	req.Header.Set("Accept", "text/event-stream")
+	if since > 0 {
+		req.Header.Set("Last-Event-ID", strconv.FormatInt(since, 10))
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if err := checkResponse(resp); err != nil {
+		return fmt.Errorf("watch failed: %w", err)
+	}
+
+	var data strings.Builder
+	scanner := bufio.NewScanner(resp.Body)
+	for scanner.Scan() {
+		line := scanner.Text()
+		switch {
+		case line == "":
+			if data.Len() == 0 {
+				continue
+			}
+			var e Event
+			if err := json.Unmarshal([]byte(data.String()), &e); err != nil {
+				return fmt.Errorf("malformed event: %w", err)
+			}
+			data.Reset()
+			if err := handle(e); err != nil {
+				return err
+			}
+		case strings.HasPrefix(line, "data:"):
+			data.WriteString(strings.TrimPrefix(strings.TrimPrefix(line, "data:"), " "))
+		}
+	}
+
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+	return io.ErrUnexpectedEOF
+}
@@ -1,43 +1,108 @@
 package config
 
 import (
+    "encoding/base64"
     "fmt"
     "os"
     "path/filepath"
+    "sort"
+    "strings"
     "gopkg.in/yaml.v3"
 )
 
 type Config struct {
-    Project     string       `yaml:"project"`
-    Server      string       `yaml:"server"`
-    Environment string       `yaml:"environment"`
-    Output      OutputConfig `yaml:"output"`
-    Secrets     []string     `yaml:"secrets,omitempty"`
+    Project     string       `yaml:"project,omitempty"`
+    Server      string       `yaml:"server,omitempty"`
+    // Profile binds the project to a named login from credentials.yaml.
+    Profile     string       `yaml:"profile,omitempty"`
+    Environment string       `yaml:"environment,omitempty"`
+    Output      OutputConfig `yaml:"output,omitempty"`
+    // Secrets declares the keys the project expects; see Schema.
+    Secrets     Schema       `yaml:"secrets,omitempty"`
     Prefix      string       `yaml:"prefix,omitempty"`
+    // OpaqueKeys stores key names encrypted, sending only HMAC-derived
+    // lookup IDs to the server.
+    OpaqueKeys  bool         `yaml:"opaque_keys,omitempty"`
+    // Templates are rendered from the secrets by every pull.
+    Templates   []Template   `yaml:"templates,omitempty"`
+    // Include names shared groups whose secrets are merged into the
+    // project's; see IncludedGroups.
+    Include     []string     `yaml:"include,omitempty"`
+
+    // Environments declares the environments the project uses, each able
+    // to override the output and prefix. When present, no other
+    // environment can be selected.
+    Environments map[string]*EnvironmentConfig `yaml:"environments,omitempty"`
+    // Protected is set on the effective config of an environment marked
+    // protected: true.
+    Protected   bool                `yaml:"-"`
+    // base is the config ForEnvironment derived this one from.
+    base        *Config
+
+    // Path is a sub-project's directory relative to the root hush.yaml.
+    // It defaults to the sub-project's name.
+    Path        string              `yaml:"path,omitempty"`
+    // Projects declares the sub-projects of a monorepo, keyed by name.
+    // Each one inherits any setting it leaves unset from the root, apart
+    // from opaque_keys.
+    Projects    map[string]*Config  `yaml:"projects,omitempty"`
+
+    // File is the hush.yaml this config was read from, and Dir the
+    // directory relative paths such as output.path are resolved against.
+    File string `yaml:"-"`
+    Dir  string `yaml:"-"`
+    // Name is the key of this config under the root's projects: map, or
+    // empty for a top-level project.
+    Name string `yaml:"-"`
+
+    // Sources records where each effective setting came from, keyed by
+    // its yaml name.
+    Sources map[string]string `yaml:"-"`
 }
 
 type OutputConfig struct {
     Format string `yaml:"format"`
     Path   string `yaml:"path"`
+    // Files is the directory file secrets and binary values are written
+    // to, since they cannot be inlined in the output file.
+    Files    string `yaml:"files,omitempty"`
+    // FileRefs, true unless set otherwise, puts the path of each file
+    // secret in the output file under its key.
+    FileRefs *bool  `yaml:"file_refs,omitempty"`
+}
+
+// Template is a text/template file that pull renders to Path. Both paths
+// are relative to the hush.yaml that declares them.
+type Template struct {
+    Template string `yaml:"template"`
+    Path     string `yaml:"path"`
 }
 
 type Credentials struct {
     Server string `yaml:"server"`
     Token  string `yaml:"token"`
+    // Profile is the name these credentials were loaded under.
+    Profile string `yaml:"-"`
+    // Sources records where the server and token came from.
+    Sources map[string]string `yaml:"-"`
 }
 
 const (
     ProjectConfigFile = "hush.yaml"
     CredentialsFile   = "credentials.yaml"
+    DefaultProfile    = "default"
 )
 
 func GetConfigDir() (string, error) {
-    home, err := os.UserHomeDir()
-    if err != nil {
-        return "", err
+    configDir := os.Getenv(EnvConfigDir)
+    if configDir == "" {
+        home, err := os.UserHomeDir()
+        if err != nil {
+            return "", err
+        }
+        configDir = filepath.Join(home, ".config", "hush")
     }
-    
-    configDir := filepath.Join(home, ".config", "hush")
+
     if err := os.MkdirAll(configDir, 0700); err != nil {
         return "", err
     }
@@ -45,84 +110,353 @@ func GetConfigDir() (string, error) {
     return configDir, nil
 }
 
-func LoadProjectConfig() (*Config, error) {
-    data, err := os.ReadFile(ProjectConfigFile)
+// LoadProjectConfig finds the nearest hush.yaml (see FindProjectConfig) and
+// applies defaults and environment variable overrides (see env.go) for the
+// environment selected by env, HUSH_ENV or hush.yaml (see
+// selectEnvironment). In a monorepo it returns the sub-project containing the
+// working directory. Without a hush.yaml, HUSH_PROJECT alone is enough to
+// describe a project, which suits CI.
+func LoadProjectConfig(env string) (*Config, error) {
+    configs, err := LoadProjectConfigs(env)
+    if err != nil {
+        return nil, err
+    }
+    if len(configs) != 1 {
+        return nil, fmt.Errorf("--env %s is only supported by 'hush pull'", AllEnvironments)
+    }
+    return configs[0], nil
+}
+
+// LoadProjectConfigs is LoadProjectConfig, but also accepts env "all" to
+// return one config per declared environment.
+func LoadProjectConfigs(env string) ([]*Config, error) {
+    cfg, err := loadBaseConfig()
+    if err != nil {
+        return nil, err
+    }
+    return cfg.forEnvironments(env)
+}
+
+// loadBaseConfig returns the project config before an environment is
+// selected.
+func loadBaseConfig() (*Config, error) {
+    path, err := FindProjectConfig()
+    if err != nil {
+        if os.IsNotExist(err) && os.Getenv(EnvProject) != "" {
+            cfg := &Config{Sources: map[string]string{}}
+            cfg.finish()
+            return cfg, nil
+        }
+        return nil, notFound(err)
+    }
+
+    root, err := readProjectConfig(path)
+    if err != nil {
+        return nil, err
+    }
+
+    cfg, err := root.forWorkingDir()
+    if err != nil {
+        return nil, err
+    }
+    cfg.finish()
+    return cfg, nil
+}
+
+// finish applies defaults and environment variable overrides.
+func (c *Config) finish() {
+    if c.Output.Format == "" {
+        c.Output.Format = "dotenv"
+        c.Sources["output.format"] = SourceDefault
+    }
+    if c.Output.Path == "" {
+        c.Output.Path = ".env"
+        c.Sources["output.path"] = SourceDefault
+    }
+    if c.Output.Files == "" {
+        c.Output.Files = ".secrets"
+        c.Sources["output.files"] = SourceDefault
+    }
+
+    applyEnvOverrides(c)
+}
+
+// OutputPath returns output.path resolved against the directory of the
+// hush.yaml it came from.
+func (c *Config) OutputPath() string {
+    return c.ProjectPath(c.Output.Path)
+}
+
+// FileRefs reports whether output.file_refs is on.
+func (c *Config) FileRefs() bool {
+    return c.Output.FileRefs == nil || *c.Output.FileRefs
+}
+
+// FilesPath returns output.files resolved like OutputPath.
+func (c *Config) FilesPath() string {
+    return c.ProjectPath(c.Output.Files)
+}
+
+// ProjectPath resolves a path from hush.yaml against its directory.
+func (c *Config) ProjectPath(path string) string {
+    if filepath.IsAbs(path) || c.Dir == "" {
+        return path
+    }
+    return filepath.Join(c.Dir, path)
+}
+
+// readProjectConfig parses the hush.yaml at path as written, without
+// defaults or overrides. The error satisfies os.IsNotExist when the file is
+// missing.
+func readProjectConfig(path string) (*Config, error) {
+    display := displayPath(path)
+    data, err := os.ReadFile(path)
     if err != nil {
         if os.IsNotExist(err) {
-            return nil, fmt.Errorf("no hush.yaml found. Run 'hush init' first")
+            return nil, err
         }
-        return nil, fmt.Errorf("failed to read %s: %w", ProjectConfigFile, err)
+        return nil, fmt.Errorf("failed to read %s: %w", display, err)
     }
 
     var cfg Config
     if err := yaml.Unmarshal(data, &cfg); err != nil {
-        return nil, fmt.Errorf("failed to parse %s: %w", ProjectConfigFile, err)
+        return nil, fmt.Errorf("failed to parse %s: %w", display, err)
     }
 
-    // Set defaults
-    if cfg.Output.Format == "" {
-        cfg.Output.Format = "dotenv"
+    cfg.File = path
+    cfg.Dir = displayPath(filepath.Dir(path))
+    cfg.Sources = cfg.ownSources(display)
+    return &cfg, nil
+}
+
+// ownSources labels every setting c sets itself with source.
+func (c *Config) ownSources(source string) map[string]string {
+    sources := map[string]string{}
+    for name, set := range map[string]bool{
+        "project":       c.Project != "",
+        "server":        c.Server != "",
+        "profile":       c.Profile != "",
+        "environment":   c.Environment != "",
+        "output.format": c.Output.Format != "",
+        "output.path":   c.Output.Path != "",
+        "prefix":        c.Prefix != "",
+        "include":       len(c.Include) > 0,
+    } {
+        if set {
+            sources[name] = source
+        }
     }
-    if cfg.Output.Path == "" {
-        cfg.Output.Path = ".env"
+    return sources
+}
+
+// UpdateProjectConfig applies fn to cfg's entry in hush.yaml as written on
+// disk and saves it, so environment overrides, defaults and settings
+// inherited from a monorepo root are never persisted.
+func UpdateProjectConfig(cfg *Config, fn func(*Config)) error {
+    if cfg.File == "" {
+        return fmt.Errorf("no %s to update", ProjectConfigFile)
     }
-    if cfg.Environment == "" {
-        cfg.Environment = "production"
+
+    root, err := readProjectConfig(cfg.File)
+    if err != nil {
+        return err
     }
 
-    return &cfg, nil
+    target := root
+    if cfg.Name != "" {
+        if target = root.Projects[cfg.Name]; target == nil {
+            return fmt.Errorf("%s no longer declares project %q", displayPath(cfg.File), cfg.Name)
+        }
+    }
+    fn(target)
+    return root.Save()
 }
 
+// Save writes the config back to the hush.yaml it was read from, or to
+// hush.yaml in the working directory for a new project.
 func (c *Config) Save() error {
     data, err := yaml.Marshal(c)
     if err != nil {
         return fmt.Errorf("failed to marshal config: %w", err)
     }
 
-    return os.WriteFile(ProjectConfigFile, data, 0644)
+    path := c.File
+    if path == "" {
+        path = ProjectConfigFile
+    }
+    return os.WriteFile(path, data, 0644)
+}
+
+// CredentialStore is the contents of credentials.yaml: named server
+// profiles and the one currently in use.
+type CredentialStore struct {
+    Current  string                  `yaml:"current"`
+    Profiles map[string]*Credentials `yaml:"profiles"`
 }
 
-func LoadCredentials() (*Credentials, error) {
+// credentialsFile also accepts the original single-server layout, which is
+// read as the "default" profile.
+type credentialsFile struct {
+    CredentialStore `yaml:",inline"`
+    Server          string `yaml:"server,omitempty"`
+    Token           string `yaml:"token,omitempty"`
+}
+
+func credentialsPath() (string, error) {
     configDir, err := GetConfigDir()
+    if err != nil {
+        return "", err
+    }
+    return filepath.Join(configDir, CredentialsFile), nil
+}
+
+// LoadCredentialStore reads every saved profile. A missing file yields an
+// empty store.
+func LoadCredentialStore() (*CredentialStore, error) {
+    credPath, err := credentialsPath()
     if err != nil {
         return nil, err
     }
 
-    credPath := filepath.Join(configDir, CredentialsFile)
+    store := &CredentialStore{Profiles: map[string]*Credentials{}}
     data, err := os.ReadFile(credPath)
     if err != nil {
         if os.IsNotExist(err) {
-            return nil, fmt.Errorf("not logged in. Run 'hush login' first")
+            return store, nil
         }
         return nil, fmt.Errorf("failed to read credentials: %w", err)
     }
 
-    var creds Credentials
-    if err := yaml.Unmarshal(data, &creds); err != nil {
+    var file credentialsFile
+    if err := yaml.Unmarshal(data, &file); err != nil {
         return nil, fmt.Errorf("failed to parse credentials: %w", err)
     }
 
-    return &creds, nil
+    if file.Current != "" {
+        store.Current = file.Current
+    }
+    for name, creds := range file.Profiles {
+        store.Profiles[name] = creds
+    }
+    if file.Server != "" && len(store.Profiles) == 0 {
+        store.Profiles[DefaultProfile] = &Credentials{Server: file.Server, Token: file.Token}
+        store.Current = DefaultProfile
+    }
+    for name, creds := range store.Profiles {
+        creds.Profile = name
+    }
+
+    return store, nil
 }
 
-func SaveCredentials(server, token string) error {
-    configDir, err := GetConfigDir()
+func (cs *CredentialStore) Save() error {
+    data, err := yaml.Marshal(cs)
+    if err != nil {
+        return err
+    }
+
+    credPath, err := credentialsPath()
     if err != nil {
         return err
     }
+    return os.WriteFile(credPath, data, 0600)
+}
+
+// Names returns the profile names in sorted order.
+func (cs *CredentialStore) Names() []string {
+    names := make([]string, 0, len(cs.Profiles))
+    for name := range cs.Profiles {
+        names = append(names, name)
+    }
+    sort.Strings(names)
+    return names
+}
+
+// Resolve picks the profile to use. In order of precedence: the explicit
+// profile (--profile), HUSH_PROFILE, the project's profile: field, the
+// profile whose server matches the project's server: field, and finally the
+// current profile. If the project names a server, the chosen profile must
+// point at it so secrets never go to the wrong place.
+func (cs *CredentialStore) Resolve(profile string, cfg *Config) (*Credentials, error) {
+    if creds, err := cs.resolveEnv(profile, cfg); creds != nil || err != nil {
+        return creds, err
+    }
+
+    name := profile
+    if name == "" {
+        name = os.Getenv("HUSH_PROFILE")
+    }
+    if name == "" && cfg != nil {
+        name = cfg.Profile
+    }
+    if name == "" && cfg != nil && cfg.Server != "" {
+        name = cs.profileForServer(cfg.Server)
+    }
+    if name == "" {
+        name = cs.Current
+    }
+    if name == "" {
+        return nil, fmt.Errorf("not logged in. Run 'hush login' first")
+    }
+
+    creds, ok := cs.Profiles[name]
+    if !ok {
+        return nil, fmt.Errorf("no profile named %q. Run 'hush login --profile %s' first", name, name)
+    }
+
+    if cfg != nil && cfg.Server != "" && !sameServer(cfg.Server, creds.Server) {
+        return nil, fmt.Errorf("%s is bound to %s but profile %q points to %s; use --profile or fix the server in %s",
+            cfg.Project, cfg.Server, name, creds.Server, ProjectConfigFile)
+    }
+
+    source := fmt.Sprintf("profile %q (%s)", name, CredentialsFile)
+    return &Credentials{
+        Server:  creds.Server,
+        Token:   creds.Token,
+        Profile: name,
+        Sources: map[string]string{"server": source, "token": source},
+    }, nil
+}
+
+// profileForServer returns the profile logged in to server, preferring the
+// current profile when several match.
+func (cs *CredentialStore) profileForServer(server string) string {
+    if c, ok := cs.Profiles[cs.Current]; ok && sameServer(c.Server, server) {
+        return cs.Current
+    }
+    for _, name := range cs.Names() {
+        if sameServer(cs.Profiles[name].Server, server) {
+            return name
+        }
+    }
+    return ""
+}
 
-    creds := Credentials{
-        Server: server,
-        Token:  token,
+func sameServer(a, b string) bool {
+    return strings.TrimRight(a, "/") == strings.TrimRight(b, "/")
+}
+
+// LoadCredentials returns the credentials for the profile selected by
+// CredentialStore.Resolve. cfg may be nil outside a project.
+func LoadCredentials(profile string, cfg *Config) (*Credentials, error) {
+    store, err := LoadCredentialStore()
+    if err != nil {
+        return nil, err
     }
+    return store.Resolve(profile, cfg)
+}
 
-    data, err := yaml.Marshal(creds)
+// SaveCredentials stores server and token under profile and makes it the
+// current profile.
+func SaveCredentials(profile, server, token string) error {
+    store, err := LoadCredentialStore()
     if err != nil {
         return err
     }
 
-    credPath := filepath.Join(configDir, CredentialsFile)
-    return os.WriteFile(credPath, data, 0600)
+    store.Profiles[profile] = &Credentials{Server: server, Token: token}
+    store.Current = profile
+    return store.Save()
 }
 
 func GetMasterKeyPath() (string, error) {
@@ -133,7 +467,17 @@ func GetMasterKeyPath() (string, error) {
     return filepath.Join(configDir, "master.key"), nil
 }
 
+// LoadMasterKey returns the encryption key from HUSH_MASTER_KEY (base64)
+// if set, otherwise from master.key in the config directory.
 func LoadMasterKey() ([]byte, error) {
+    if v := os.Getenv(EnvMasterKey); v != "" {
+        key, err := base64.StdEncoding.DecodeString(strings.TrimSpace(v))
+        if err != nil {
+            return nil, fmt.Errorf("%s is not valid base64: %w", EnvMasterKey, err)
+        }
+        return key, nil
+    }
+
     path, err := GetMasterKeyPath()
     if err != nil {
         return nil, err
@@ -141,6 +485,18 @@ func LoadMasterKey() ([]byte, error) {
     return os.ReadFile(path)
 }
 
+// MasterKeySource describes where LoadMasterKey reads the key from.
+func MasterKeySource() string {
+    if os.Getenv(EnvMasterKey) != "" {
+        return EnvMasterKey
+    }
+    path, err := GetMasterKeyPath()
+    if err != nil {
+        return "unavailable: " + err.Error()
+    }
+    return path
+}
+
 func SaveMasterKey(key []byte) error {
     path, err := GetMasterKeyPath()
     if err != nil {
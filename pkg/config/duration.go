@@ -0,0 +1,29 @@
+package config
+
+import (
+    "fmt"
+    "strconv"
+    "strings"
+    "time"
+)
+
+// ParseDuration parses a duration such as "90d", "2w" or "36h". Days and
+// weeks are added to the units time.ParseDuration accepts, since expiry
+// and rotation intervals are usually given in them.
+func ParseDuration(s string) (time.Duration, error) {
+    for suffix, unit := range map[string]time.Duration{"d": 24 * time.Hour, "w": 7 * 24 * time.Hour} {
+        if n, ok := strings.CutSuffix(s, suffix); ok {
+            days, err := strconv.Atoi(n)
+            if err != nil || days <= 0 {
+                return 0, fmt.Errorf("invalid duration %q (use e.g. 90d, 2w or 12h)", s)
+            }
+            return time.Duration(days) * unit, nil
+        }
+    }
+
+    d, err := time.ParseDuration(s)
+    if err != nil || d <= 0 {
+        return 0, fmt.Errorf("invalid duration %q (use e.g. 90d, 2w or 12h)", s)
+    }
+    return d, nil
+}
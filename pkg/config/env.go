@@ -0,0 +1,83 @@
+package config
+
+import (
+    "fmt"
+    "os"
+)
+
+// Environment variables that override file-based configuration. Each one
+// takes precedence over hush.yaml, which takes precedence over
+// credentials.yaml and master.key, which take precedence over defaults:
+//
+//   HUSH_SERVER      server URL              (over the profile / hush.yaml server)
+//   HUSH_TOKEN       API token               (over the profile's token)
+//   HUSH_MASTER_KEY  base64 encryption key   (over master.key)
+//   HUSH_PROJECT     project name            (over hush.yaml project)
+//   HUSH_ENV         environment name        (over hush.yaml environment; --env wins)
+//   HUSH_CONFIG_DIR  config directory        (over ~/.config/hush)
+//   HUSH_PROFILE     credentials profile     (over hush.yaml profile)
+const (
+    EnvServer    = "HUSH_SERVER"
+    EnvToken     = "HUSH_TOKEN"
+    EnvMasterKey = "HUSH_MASTER_KEY"
+    EnvProject   = "HUSH_PROJECT"
+    EnvEnv       = "HUSH_ENV"
+    EnvConfigDir = "HUSH_CONFIG_DIR"
+    EnvProfile   = "HUSH_PROFILE"
+)
+
+// SourceDefault marks a setting that fell back to its built-in default.
+const SourceDefault = "default"
+
+func applyEnvOverrides(cfg *Config) {
+    if v := os.Getenv(EnvProject); v != "" {
+        cfg.Project = v
+        cfg.Sources["project"] = EnvProject
+    }
+}
+
+// resolveEnv builds credentials from HUSH_TOKEN and HUSH_SERVER. It returns
+// nil, nil when neither is set so profile resolution can continue.
+func (cs *CredentialStore) resolveEnv(profile string, cfg *Config) (*Credentials, error) {
+    server, token := os.Getenv(EnvServer), os.Getenv(EnvToken)
+    if server == "" && token == "" {
+        return nil, nil
+    }
+
+    creds := &Credentials{Server: server, Token: token, Sources: map[string]string{}}
+    if server != "" {
+        creds.Sources["server"] = EnvServer
+    }
+    if token != "" {
+        creds.Sources["token"] = EnvToken
+    }
+
+    if token == "" {
+        // Borrow the token from the named profile, or whichever profile is
+        // logged in to HUSH_SERVER, but never send a profile's token to a
+        // server it wasn't issued for.
+        name := profile
+        if name == "" {
+            name = os.Getenv(EnvProfile)
+        }
+        if name == "" {
+            name = cs.profileForServer(server)
+        }
+        saved, ok := cs.Profiles[name]
+        if !ok || !sameServer(saved.Server, server) {
+            return nil, fmt.Errorf("%s is set but no token is available for it; set %s too", EnvServer, EnvToken)
+        }
+        creds.Token, creds.Profile = saved.Token, name
+        creds.Sources["token"] = fmt.Sprintf("profile %q (%s)", name, CredentialsFile)
+    }
+
+    if server == "" {
+        if cfg == nil || cfg.Server == "" {
+            return nil, fmt.Errorf("%s is set but no server is known; set %s too", EnvToken, EnvServer)
+        }
+        creds.Server = cfg.Server
+        creds.Sources["server"] = cfg.Sources["server"]
+    }
+
+    return creds, nil
+}
@@ -0,0 +1,164 @@
+package config
+
+import (
+    "fmt"
+    "os"
+    "sort"
+    "strings"
+)
+
+// EnvironmentConfig is an entry under environments: in hush.yaml. Unset
+// fields fall back to the project-level settings.
+type EnvironmentConfig struct {
+    Output OutputConfig `yaml:"output,omitempty"`
+    Prefix string       `yaml:"prefix,omitempty"`
+    // Protected makes commands that act on the environment ask for
+    // confirmation first.
+    Protected bool `yaml:"protected,omitempty"`
+    // Templates replace the project's templates for this environment, so
+    // each one can render to its own destination.
+    Templates []Template `yaml:"templates,omitempty"`
+}
+
+const (
+    // DefaultEnvironment is used when nothing else selects one.
+    DefaultEnvironment = "development"
+    // AllEnvironments selects every declared environment.
+    AllEnvironments = "all"
+)
+
+// EnvironmentNames returns the declared environments in sorted order.
+func (c *Config) EnvironmentNames() []string {
+    names := make([]string, 0, len(c.Environments))
+    for name := range c.Environments {
+        names = append(names, name)
+    }
+    sort.Strings(names)
+    return names
+}
+
+// selectEnvironment picks the environment to use. In order of precedence:
+// env (the --env flag), HUSH_ENV, environment: in hush.yaml, the only
+// declared environment, and finally DefaultEnvironment. With several
+// environments declared and none chosen, it refuses to guess.
+func (c *Config) selectEnvironment(env string) (name, source string, err error) {
+    switch {
+    case env != "":
+        return env, "--env", nil
+    case os.Getenv(EnvEnv) != "":
+        return os.Getenv(EnvEnv), EnvEnv, nil
+    case c.Environment != "":
+        return c.Environment, c.Sources["environment"], nil
+    case len(c.Environments) == 1:
+        return c.EnvironmentNames()[0], c.label("environments"), nil
+    case len(c.Environments) > 1:
+        return "", "", fmt.Errorf("%s declares environments %s; choose one with --env or %s",
+            c.label(""), strings.Join(c.EnvironmentNames(), ", "), EnvEnv)
+    }
+    return DefaultEnvironment, SourceDefault, nil
+}
+
+// forEnvironments returns the config for the selected environment, or for
+// every declared environment when the selection is "all".
+func (c *Config) forEnvironments(env string) ([]*Config, error) {
+    name, source, err := c.selectEnvironment(env)
+    if err != nil {
+        return nil, err
+    }
+
+    if name != AllEnvironments {
+        cfg, err := c.ForEnvironment(name)
+        if err != nil {
+            return nil, err
+        }
+        cfg.Sources["environment"] = source
+        return []*Config{cfg}, nil
+    }
+
+    if len(c.Environments) == 0 {
+        return nil, fmt.Errorf("--env %s needs environments: declared in %s", AllEnvironments, ProjectConfigFile)
+    }
+    var configs []*Config
+    for _, name := range c.EnvironmentNames() {
+        cfg, err := c.ForEnvironment(name)
+        if err != nil {
+            return nil, err
+        }
+        cfg.Sources["environment"] = source
+        configs = append(configs, cfg)
+    }
+    return configs, nil
+}
+
+// ForEnvironment returns a copy of c targeting environment name, with that
+// environment's output, prefix and protection applied. If environments are
+// declared, name must be one of them.
+func (c *Config) ForEnvironment(name string) (*Config, error) {
+    base := c
+    if c.base != nil {
+        base = c.base
+    }
+
+    env := base.Environments[name]
+    if len(base.Environments) > 0 && env == nil {
+        return nil, fmt.Errorf("environment %q is not declared in %s (declared: %s)",
+            name, base.label(""), strings.Join(base.EnvironmentNames(), ", "))
+    }
+
+    cfg := *base
+    cfg.base = base
+    cfg.Environment = name
+    cfg.Sources = make(map[string]string, len(base.Sources))
+    for k, v := range base.Sources {
+        cfg.Sources[k] = v
+    }
+
+    if env != nil {
+        source := base.label("environments." + name)
+        if env.Output.Path != "" {
+            cfg.Output.Path = env.Output.Path
+            cfg.Sources["output.path"] = source
+        }
+        if env.Output.Format != "" {
+            cfg.Output.Format = env.Output.Format
+            cfg.Sources["output.format"] = source
+        }
+        if env.Output.Files != "" {
+            cfg.Output.Files = env.Output.Files
+            cfg.Sources["output.files"] = source
+        }
+        if env.Output.FileRefs != nil {
+            cfg.Output.FileRefs = env.Output.FileRefs
+            cfg.Sources["output.file_refs"] = source
+        }
+        if env.Prefix != "" {
+            cfg.Prefix = env.Prefix
+            cfg.Sources["prefix"] = source
+        }
+        if env.Protected {
+            cfg.Protected = true
+            cfg.Sources["protected"] = source
+        }
+        if env.Templates != nil {
+            cfg.Templates = env.Templates
+        }
+    }
+
+    return &cfg, nil
+}
+
+// label names the hush.yaml entry a setting came from, optionally followed
+// by a dotted path within it.
+func (c *Config) label(field string) string {
+    file := ProjectConfigFile
+    if c.File != "" {
+        file = displayPath(c.File)
+    }
+    if c.Name != "" {
+        field = strings.TrimSuffix("projects."+c.Name+"."+field, ".")
+    }
+    if field == "" {
+        return file
+    }
+    return fmt.Sprintf("%s (%s)", file, field)
+}
@@ -0,0 +1,87 @@
+package config
+
+import (
+    "fmt"
+    "strings"
+)
+
+// GroupPrefix marks the project a shared group's secrets are stored under
+// on the server: group "smtp" is project "@smtp". hushd uses the same
+// convention to list groups.
+const GroupPrefix = "@"
+
+// Group returns the config for editing or fetching the shared group name
+// in c's environment, on c's server. Settings that only make sense for a
+// project, such as the schema, prefix and opaque_keys, are dropped.
+func (c *Config) Group(name string) (*Config, error) {
+    if name == "" || strings.ContainsAny(name, "/@ \t") {
+        return nil, fmt.Errorf("invalid group name %q", name)
+    }
+
+    group := &Config{
+        Project:     GroupPrefix + name,
+        Server:      c.Server,
+        Profile:     c.Profile,
+        Environment: c.Environment,
+        Output:      c.Output,
+        Protected:   c.Protected,
+        File:        c.File,
+        Dir:         c.Dir,
+        Sources:     make(map[string]string, len(c.Sources)),
+    }
+    for k, v := range c.Sources {
+        group.Sources[k] = v
+    }
+    group.Sources["project"] = "--group"
+    return group, nil
+}
+
+// LoadGroupConfig returns the config for the shared group name. The server,
+// profile and environment come from the nearest hush.yaml as for
+// LoadProjectConfig, but groups can be managed without one.
+func LoadGroupConfig(name, env string) (*Config, error) {
+    cfg, err := LoadProjectConfig(env)
+    if err == ErrNoProjectConfig {
+        base := &Config{Sources: map[string]string{}}
+        base.finish()
+        envName, source, err := base.selectEnvironment(env)
+        if err != nil {
+            return nil, err
+        }
+        if cfg, err = base.ForEnvironment(envName); err != nil {
+            return nil, err
+        }
+        cfg.Sources["environment"] = source
+    } else if err != nil {
+        return nil, err
+    }
+    return cfg.Group(name)
+}
+
+// IncludedGroups returns the groups listed under include:, checked as
+// group names.
+func (c *Config) IncludedGroups() ([]*Config, error) {
+    var groups []*Config
+    seen := map[string]bool{}
+    for _, name := range c.Include {
+        if seen[name] {
+            return nil, fmt.Errorf("%s includes %q twice", c.label("include"), name)
+        }
+        seen[name] = true
+        group, err := c.Group(name)
+        if err != nil {
+            return nil, fmt.Errorf("%s: %w", c.label("include"), err)
+        }
+        groups = append(groups, group)
+    }
+    return groups, nil
+}
+
+// GroupName returns the group a config from Group refers to, or "" for a
+// project.
+func (c *Config) GroupName() string {
+    if name, ok := strings.CutPrefix(c.Project, GroupPrefix); ok {
+        return name
+    }
+    return ""
+}
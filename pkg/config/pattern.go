@@ -0,0 +1,62 @@
+package config
+
+import (
+    "fmt"
+    "regexp"
+    "strings"
+)
+
+// Pattern matches key names and metadata for hush search and hushd's
+// /api/search, so both sides agree on what a pattern means.
+type Pattern struct {
+    re *regexp.Regexp
+}
+
+// CompilePattern parses a search pattern. Globs (* and ?) must match the
+// whole string and ignore case, so AWS_* finds aws_secret too; regular
+// expressions match anywhere in it, as grep does, and are case-sensitive
+// unless they start with (?i).
+func CompilePattern(pattern string, regex bool) (*Pattern, error) {
+    if pattern == "" {
+        return nil, fmt.Errorf("empty search pattern")
+    }
+    expr := pattern
+    if !regex {
+        expr = regexp.QuoteMeta(pattern)
+        expr = strings.ReplaceAll(expr, `\*`, `.*`)
+        expr = strings.ReplaceAll(expr, `\?`, `.`)
+        expr = `(?is)^` + expr + `$`
+    }
+
+    re, err := regexp.Compile(expr)
+    if err != nil {
+        return nil, fmt.Errorf("invalid pattern %q: %v", pattern, err)
+    }
+    return &Pattern{re: re}, nil
+}
+
+// Match reports whether s matches the pattern.
+func (p *Pattern) Match(s string) bool {
+    return s != "" && p.re.MatchString(s)
+}
+
+// MatchField returns the name of the first field that matches: "key",
+// "description", "owner", "link" or "tag", or "" if none does.
+func (p *Pattern) MatchField(key, description, owner string, tags []string, link string) string {
+    switch {
+    case p.Match(key):
+        return "key"
+    case p.Match(description):
+        return "description"
+    case p.Match(owner):
+        return "owner"
+    case p.Match(link):
+        return "link"
+    }
+    for _, tag := range tags {
+        if p.Match(tag) {
+            return "tag"
+        }
+    }
+    return ""
+}
@@ -0,0 +1,200 @@
+package config
+
+import (
+    "encoding/pem"
+    "fmt"
+    "net/url"
+    "regexp"
+    "strconv"
+
+    "gopkg.in/yaml.v3"
+)
+
+// Schema is the secrets: section of hush.yaml: the keys a project expects
+// and what their values must look like. It is written either as a list of
+// key names, each of them required,
+//
+//   secrets: [DATABASE_URL, API_KEY]
+//
+// or as a map from key name to a SecretSpec:
+//
+//   secrets:
+//     DATABASE_URL: {type: url, description: Primary database}
+//     PORT: {type: int, default: "8080"}
+//     LOG_LEVEL: {pattern: "debug|info|warn", required: false}
+type Schema []SecretSpec
+
+// SecretSpec describes one expected key.
+type SecretSpec struct {
+    Name        string `yaml:"-"`
+    Description string `yaml:"description,omitempty"`
+    // Required defaults to true unless the key has a default.
+    Required    *bool  `yaml:"required,omitempty"`
+    // Type is one of url, int, bool or pem.
+    Type        string `yaml:"type,omitempty"`
+    // Pattern is a regular expression the whole value must match.
+    Pattern     string `yaml:"pattern,omitempty"`
+    // Default is used by pull when the key is not set.
+    Default     string `yaml:"default,omitempty"`
+}
+
+// Violation is a key that does not satisfy the schema.
+type Violation struct {
+    Key     string `json:"key"`
+    Problem string `json:"problem"`
+}
+
+func (v Violation) String() string {
+    return v.Key + ": " + v.Problem
+}
+
+func (s *Schema) UnmarshalYAML(node *yaml.Node) error {
+    switch node.Kind {
+    case yaml.SequenceNode:
+        var names []string
+        if err := node.Decode(&names); err != nil {
+            return err
+        }
+        *s = nil
+        for _, name := range names {
+            *s = append(*s, SecretSpec{Name: name})
+        }
+    case yaml.MappingNode:
+        *s = nil
+        for i := 0; i+1 < len(node.Content); i += 2 {
+            var spec SecretSpec
+            if err := node.Content[i+1].Decode(&spec); err != nil {
+                return fmt.Errorf("secrets.%s: %w", node.Content[i].Value, err)
+            }
+            spec.Name = node.Content[i].Value
+            *s = append(*s, spec)
+        }
+    default:
+        return fmt.Errorf("line %d: secrets must be a list of key names or a map of key specs", node.Line)
+    }
+
+    for _, spec := range *s {
+        if err := spec.check(); err != nil {
+            return fmt.Errorf("secrets.%s: %w", spec.Name, err)
+        }
+    }
+    return nil
+}
+
+// MarshalYAML keeps the short list form when no key needs more than a name.
+func (s Schema) MarshalYAML() (interface{}, error) {
+    plain := true
+    for _, spec := range s {
+        if spec != (SecretSpec{Name: spec.Name}) {
+            plain = false
+        }
+    }
+    if plain {
+        names := make([]string, len(s))
+        for i, spec := range s {
+            names[i] = spec.Name
+        }
+        return names, nil
+    }
+
+    node := &yaml.Node{Kind: yaml.MappingNode}
+    for _, spec := range s {
+        var value yaml.Node
+        if err := value.Encode(spec); err != nil {
+            return nil, err
+        }
+        node.Content = append(node.Content, &yaml.Node{Kind: yaml.ScalarNode, Value: spec.Name}, &value)
+    }
+    return node, nil
+}
+
+// Lookup returns the spec for key, or nil if the schema does not mention it.
+func (s Schema) Lookup(key string) *SecretSpec {
+    for i := range s {
+        if s[i].Name == key {
+            return &s[i]
+        }
+    }
+    return nil
+}
+
+// Check validates values against the schema, filling in defaults for keys
+// that are missing. Keys the schema does not mention are left alone.
+func (s Schema) Check(values map[string]string) []Violation {
+    var violations []Violation
+    for _, spec := range s {
+        value, ok := values[spec.Name]
+        if !ok {
+            if spec.Default != "" {
+                values[spec.Name] = spec.Default
+            } else if spec.IsRequired() {
+                violations = append(violations, Violation{spec.Name, "required but not set"})
+            }
+            continue
+        }
+        if err := spec.Validate(value); err != nil {
+            violations = append(violations, Violation{spec.Name, err.Error()})
+        }
+    }
+    return violations
+}
+
+func (spec SecretSpec) IsRequired() bool {
+    if spec.Required != nil {
+        return *spec.Required
+    }
+    return spec.Default == ""
+}
+
+// Validate reports whether value has the spec's type and matches its
+// pattern. The error never includes the value itself.
+func (spec SecretSpec) Validate(value string) error {
+    switch spec.Type {
+    case "":
+    case "url":
+        u, err := url.Parse(value)
+        if err != nil || u.Scheme == "" || (u.Host == "" && u.Opaque == "") {
+            return fmt.Errorf("not a valid URL")
+        }
+    case "int":
+        if _, err := strconv.ParseInt(value, 10, 64); err != nil {
+            return fmt.Errorf("not an integer")
+        }
+    case "bool":
+        if _, err := strconv.ParseBool(value); err != nil {
+            return fmt.Errorf("not a boolean (use true or false)")
+        }
+    case "pem":
+        if block, _ := pem.Decode([]byte(value)); block == nil {
+            return fmt.Errorf("not a PEM block")
+        }
+    }
+
+    if spec.Pattern != "" {
+        re := regexp.MustCompile("^(?:" + spec.Pattern + ")$")
+        if !re.MatchString(value) {
+            return fmt.Errorf("does not match pattern %s", spec.Pattern)
+        }
+    }
+    return nil
+}
+
+// check rejects specs that could never be satisfied.
+func (spec SecretSpec) check() error {
+    switch spec.Type {
+    case "", "url", "int", "bool", "pem":
+    default:
+        return fmt.Errorf("unknown type %q (use url, int, bool or pem)", spec.Type)
+    }
+    if spec.Pattern != "" {
+        if _, err := regexp.Compile("^(?:" + spec.Pattern + ")$"); err != nil {
+            return fmt.Errorf("invalid pattern: %w", err)
+        }
+    }
+    if spec.Default != "" {
+        if err := spec.Validate(spec.Default); err != nil {
+            return fmt.Errorf("default is %v", err)
+        }
+    }
+    return nil
+}
@@ -0,0 +1,228 @@
+package config
+
+import (
+    "fmt"
+    "os"
+    "path/filepath"
+    "sort"
+    "strings"
+)
+
+// FindProjectConfig returns the path of the nearest hush.yaml, searching the
+// working directory and then each parent up to the root of the enclosing git
+// repository (or the filesystem root outside one). The error satisfies
+// os.IsNotExist when there is none.
+func FindProjectConfig() (string, error) {
+    dir, err := os.Getwd()
+    if err != nil {
+        return "", err
+    }
+    return findUp(dir)
+}
+
+func findUp(dir string) (string, error) {
+    for {
+        path := filepath.Join(dir, ProjectConfigFile)
+        if _, err := os.Stat(path); err == nil {
+            return path, nil
+        } else if !os.IsNotExist(err) {
+            return "", err
+        }
+
+        parent := filepath.Dir(dir)
+        if isRepoRoot(dir) || parent == dir {
+            return "", os.ErrNotExist
+        }
+        dir = parent
+    }
+}
+
+// ErrNoProjectConfig is returned when there is no hush.yaml to load.
+var ErrNoProjectConfig = fmt.Errorf("no %s found. Run 'hush init' first", ProjectConfigFile)
+
+// notFound turns a missing hush.yaml into ErrNoProjectConfig.
+func notFound(err error) error {
+    if os.IsNotExist(err) {
+        return ErrNoProjectConfig
+    }
+    return err
+}
+
+func isRepoRoot(dir string) bool {
+    _, err := os.Stat(filepath.Join(dir, ".git"))
+    return err == nil
+}
+
+// LoadAllProjectConfigs returns every project in the monorepo around the
+// working directory: the nearest hush.yaml that declares projects:, or
+// failing that the nearest hush.yaml on its own. The root's own project, if
+// it names one, comes first, followed by its sub-projects sorted by name.
+//
+// env selects the environment of each project as for LoadProjectConfigs.
+func LoadAllProjectConfigs(env string) ([]*Config, error) {
+    if os.Getenv(EnvProject) != "" {
+        return nil, fmt.Errorf("%s selects a single project and cannot be used with --all", EnvProject)
+    }
+
+    path, err := FindProjectConfig()
+    if err != nil {
+        return nil, notFound(err)
+    }
+    root, err := readProjectConfig(path)
+    if err != nil {
+        return nil, err
+    }
+
+    // A service may keep its own hush.yaml inside the monorepo; keep
+    // looking for the root that lists the others.
+    for len(root.Projects) == 0 && !isRepoRoot(filepath.Dir(path)) {
+        parent, err := findUp(filepath.Dir(filepath.Dir(path)))
+        if os.IsNotExist(err) {
+            break
+        } else if err != nil {
+            return nil, err
+        }
+        next, err := readProjectConfig(parent)
+        if err != nil {
+            return nil, err
+        }
+        if len(next.Projects) > 0 {
+            root = next
+        }
+        path = parent
+    }
+
+    var projects []*Config
+    if root.Project != "" || len(root.Projects) == 0 {
+        projects = append(projects, root)
+    }
+    for _, name := range root.projectNames() {
+        projects = append(projects, root.subProject(name))
+    }
+
+    var configs []*Config
+    for _, project := range projects {
+        project.finish()
+        envs, err := project.forEnvironments(env)
+        if err != nil {
+            return nil, fmt.Errorf("%s: %w", project.Project, err)
+        }
+        configs = append(configs, envs...)
+    }
+    return configs, nil
+}
+
+// forWorkingDir returns the sub-project whose path contains the working
+// directory, or c itself when there is none.
+func (c *Config) forWorkingDir() (*Config, error) {
+    if len(c.Projects) == 0 {
+        return c, nil
+    }
+
+    cwd, err := os.Getwd()
+    if err != nil {
+        return nil, err
+    }
+    rootDir := filepath.Dir(c.File)
+
+    match, depth := "", -1
+    for _, name := range c.projectNames() {
+        dir := filepath.Join(rootDir, c.Projects[name].subPath(name))
+        rel, err := filepath.Rel(dir, cwd)
+        if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+            continue
+        }
+        if d := len(dir); d > depth {
+            match, depth = name, d
+        }
+    }
+
+    if match != "" {
+        return c.subProject(match), nil
+    }
+    if c.Project == "" {
+        return nil, fmt.Errorf("%s declares projects %s; run from inside one of them or use 'hush pull --all'",
+            displayPath(c.File), strings.Join(c.projectNames(), ", "))
+    }
+    return c, nil
+}
+
+// subProject returns the sub-project name with every setting it leaves
+// unset inherited from c, except opaque_keys which changes how its secrets
+// are stored. Its paths are relative to its own directory.
+func (c *Config) subProject(name string) *Config {
+    sub := *c.Projects[name]
+    sub.Projects = nil
+    sub.Name = name
+    sub.File = c.File
+    sub.Dir = displayPath(filepath.Join(filepath.Dir(c.File), sub.subPath(name)))
+    sub.Sources = sub.ownSources(sub.label(""))
+
+    if sub.Project == "" {
+        sub.Project = name
+        sub.Sources["project"] = sub.label("") + " key"
+    }
+    for _, field := range []struct {
+        name     string
+        sub      *string
+        inherits string
+    }{
+        {"server", &sub.Server, c.Server},
+        {"profile", &sub.Profile, c.Profile},
+        {"environment", &sub.Environment, c.Environment},
+        {"output.format", &sub.Output.Format, c.Output.Format},
+        {"output.path", &sub.Output.Path, c.Output.Path},
+        {"output.files", &sub.Output.Files, c.Output.Files},
+        {"prefix", &sub.Prefix, c.Prefix},
+    } {
+        if *field.sub == "" && field.inherits != "" {
+            *field.sub = field.inherits
+            sub.Sources[field.name] = c.Sources[field.name]
+        }
+    }
+    if sub.Output.FileRefs == nil {
+        sub.Output.FileRefs = c.Output.FileRefs
+    }
+    if sub.Secrets == nil {
+        sub.Secrets = c.Secrets
+    }
+    if sub.Include == nil && c.Include != nil {
+        sub.Include = c.Include
+        sub.Sources["include"] = c.Sources["include"]
+    }
+    if sub.Environments == nil {
+        sub.Environments = c.Environments
+    }
+
+    return &sub
+}
+
+func (c *Config) subPath(name string) string {
+    if c.Path != "" {
+        return c.Path
+    }
+    return name
+}
+
+func (c *Config) projectNames() []string {
+    names := make([]string, 0, len(c.Projects))
+    for name := range c.Projects {
+        names = append(names, name)
+    }
+    sort.Strings(names)
+    return names
+}
+
+// displayPath shortens path relative to the working directory when that is
+// possible, for messages and relative output paths.
+func displayPath(path string) string {
+    cwd, err := os.Getwd()
+    if err != nil {
+        return path
+    }
+    rel, err := filepath.Rel(cwd, path)
+    if err != nil {
+        return path
+    }
+    return rel
+}
@@ -3,9 +3,11 @@ package crypto
 import (
 	"crypto/aes"
 	"crypto/cipher"
+	"crypto/hmac"
 	"crypto/rand"
 	"crypto/sha256"
 	"encoding/base64"
+	"encoding/hex"
 	"errors"
 	"io"
 
@@ -68,6 +70,24 @@ func Decrypt(ciphertext string, key []byte) (string, error) {
 	return string(plaintext), nil
 }
 
+// LookupID derives the opaque identifier stored on the server in place of a
+// key name. It is deterministic per project, so the server can still upsert
+// by key, and the same name in every environment maps to the same ID. The
+// HMAC is keyed with a subkey of the master key, never the encryption key
+// itself.
+func LookupID(project, name string, key []byte) string {
+	mac := hmac.New(sha256.New, subkey(key, "hush-key-lookup"))
+	mac.Write([]byte(project + "\x00" + name))
+	return "hk_" + hex.EncodeToString(mac.Sum(nil)[:16])
+}
+
+// subkey derives a key for one purpose from the master key.
+func subkey(key []byte, purpose string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(purpose))
+	return mac.Sum(nil)
+}
+
 func GenerateSalt() ([]byte, error) {
 	salt := make([]byte, 32)
 	_, err := rand.Read(salt)
@@ -0,0 +1,185 @@
+package storage
+
+import (
+    "database/sql"
+    "strconv"
+    "strings"
+    "time"
+
+    _ "github.com/lib/pq"
+)
+
+// Backend is everything hushd needs from persistent storage. *Store
+// implements it for SQLite and PostgreSQL; storagetest.Run checks that an
+// implementation behaves like the others.
+type Backend interface {
+    // Secrets
+    UpsertSecret(secret *Secret) error
+    GetSecrets(project, environment string) ([]Secret, error)
+    // GetSecret returns sql.ErrNoRows when the key is not set.
+    GetSecret(project, environment, key string) (*Secret, error)
+    DeleteSecret(project, environment, key string) (bool, error)
+    // ListGroups returns the names of the shared groups that have secrets.
+    ListGroups() ([]string, error)
+    // AllSecrets returns the secrets of every project and environment.
+    AllSecrets() ([]Secret, error)
+
+    // ExpiringSecrets returns the secrets due for replacement before the
+    // given time, soonest first, without their values.
+    ExpiringSecrets(before time.Time) ([]Secret, error)
+    MarkExpiryNotice(id int, notice string) error
+
+    // Environment inheritance
+    // GetParent returns "" when the environment inherits from none.
+    GetParent(project, environment string) (string, error)
+    // SetParent with an empty parent stops the environment inheriting.
+    SetParent(project, environment, parent string) error
+
+    // Tokens
+    CreateAdminToken() (string, error)
+    ValidateToken(token string) bool
+    LookupToken(token string) (*Token, error)
+
+    // Change events
+    AppendEvent(e *Event) error
+    EventsSince(project, environment string, after int64) ([]Event, error)
+
+    // Webhooks
+    CreateWebhook(w *Webhook) error
+    ListWebhooks() ([]Webhook, error)
+    GetWebhook(id int64) (*Webhook, error)
+    DeleteWebhook(id int64) (bool, error)
+    EnqueueDeliveries(event, project, env, payload string) (int, error)
+    DueDeliveries(now time.Time, limit int) ([]Delivery, error)
+    DeadDeliveries() ([]Delivery, error)
+    MarkDelivered(id int64) error
+    MarkFailed(id int64, reason string, next time.Time) error
+    RetryDelivery(id int64) (bool, error)
+
+    // Schema
+    SchemaVersion() (int, error)
+    MigrationStatus() ([]MigrationState, error)
+    Migrate() ([]Migration, error)
+
+    // Backup writes a consistent copy of the database to path. Backends
+    // with their own tooling (pg_dump) may return ErrBackupUnsupported.
+    Backup(path string) (*Manifest, error)
+
+    // Metadata encryption
+    Encrypted() (bool, error)
+    Rekey(newKey []byte) error
+
+    Close() error
+}
+
+var _ Backend = (*Store)(nil)
+
+// dialect captures the differences between the SQL databases Store runs on.
+// Queries are written for SQLite and translated on the way out.
+type dialect struct {
+    name       string
+    driver     string
+    migrations string
+    // numbered rewrites ? placeholders to $1, $2, ...
+    numbered bool
+    // now replaces CURRENT_TIMESTAMP so every backend stores UTC
+    // "YYYY-MM-DD HH:MM:SS" text.
+    now string
+    // migrationsTable creates the schema_migrations bookkeeping table.
+    migrationsTable string
+}
+
+var sqliteDialect = &dialect{
+    name:       "sqlite",
+    driver:     "sqlite",
+    migrations: "migrations/sqlite",
+    now:        "CURRENT_TIMESTAMP",
+    migrationsTable: `CREATE TABLE IF NOT EXISTS schema_migrations (
+        version INTEGER PRIMARY KEY,
+        name TEXT NOT NULL,
+        applied_at DATETIME DEFAULT CURRENT_TIMESTAMP
+    )`,
+}
+
+var postgresDialect = &dialect{
+    name:       "postgres",
+    driver:     "postgres",
+    migrations: "migrations/postgres",
+    numbered:   true,
+    now:        "to_char(now() AT TIME ZONE 'UTC', 'YYYY-MM-DD HH24:MI:SS')",
+    migrationsTable: `CREATE TABLE IF NOT EXISTS schema_migrations (
+        version INTEGER PRIMARY KEY,
+        name TEXT NOT NULL,
+        applied_at TEXT NOT NULL DEFAULT to_char(now() AT TIME ZONE 'UTC', 'YYYY-MM-DD HH24:MI:SS')
+    )`,
+}
+
+// IsPostgres reports whether dsn selects the PostgreSQL backend. Anything
+// else is treated as a path to a SQLite database file.
+func IsPostgres(dsn string) bool {
+    return strings.HasPrefix(dsn, "postgres://") || strings.HasPrefix(dsn, "postgresql://")
+}
+
+func (d *dialect) rebind(query string) string {
+    if d.now != "CURRENT_TIMESTAMP" {
+        query = strings.ReplaceAll(query, "CURRENT_TIMESTAMP", d.now)
+    }
+    if !d.numbered {
+        return query
+    }
+
+    var b strings.Builder
+    n := 0
+    inString := false
+    for _, r := range query {
+        switch {
+        case r == '\'':
+            inString = !inString
+        case r == '?' && !inString:
+            n++
+            b.WriteString("$" + strconv.Itoa(n))
+            continue
+        }
+        b.WriteRune(r)
+    }
+    return b.String()
+}
+
+// db wraps *sql.DB so every query is translated for the dialect.
+type db struct {
+    *sql.DB
+    dialect *dialect
+}
+
+func (d *db) Exec(query string, args ...any) (sql.Result, error) {
+    return d.DB.Exec(d.dialect.rebind(query), args...)
+}
+
+func (d *db) Query(query string, args ...any) (*sql.Rows, error) {
+    return d.DB.Query(d.dialect.rebind(query), args...)
+}
+
+func (d *db) QueryRow(query string, args ...any) *sql.Row {
+    return d.DB.QueryRow(d.dialect.rebind(query), args...)
+}
+
+func (d *db) Begin() (*tx, error) {
+    t, err := d.DB.Begin()
+    if err != nil {
+        return nil, err
+    }
+    return &tx{Tx: t, dialect: d.dialect}, nil
+}
+
+type tx struct {
+    *sql.Tx
+    dialect *dialect
+}
+
+func (t *tx) Exec(query string, args ...any) (sql.Result, error) {
+    return t.Tx.Exec(t.dialect.rebind(query), args...)
+}
+
+func (t *tx) Query(query string, args ...any) (*sql.Rows, error) {
+    return t.Tx.Query(t.dialect.rebind(query), args...)
+}
@@ -0,0 +1,179 @@
+package storage
+
+import (
+    "crypto/sha256"
+    "database/sql"
+    "encoding/hex"
+    "encoding/json"
+    "errors"
+    "fmt"
+    "io"
+    "os"
+    "time"
+)
+
+// Manifest describes a backup file so it can be verified before restoring.
+// It is written next to the backup as <path>.manifest.json.
+type Manifest struct {
+    CreatedAt     string           `json:"created_at"`
+    SchemaVersion int              `json:"schema_version"`
+    Size          int64            `json:"size"`
+    SHA256        string           `json:"sha256"`
+    Counts        map[string]int64 `json:"counts"`
+}
+
+// ErrBackupUnsupported is returned by backends whose databases should be
+// backed up with their own tooling.
+var ErrBackupUnsupported = errors.New("backup is only supported for SQLite databases; use pg_dump for PostgreSQL")
+
+// tables lists every table included in backup row counts.
+var tables = []string{"secrets", "environment_parents", "tokens", "events", "webhooks", "webhook_deliveries", "settings"}
+
+// ManifestPath returns where the manifest for a backup file lives.
+func ManifestPath(backupPath string) string {
+    return backupPath + ".manifest.json"
+}
+
+// Backup writes a consistent copy of the live database to path using
+// VACUUM INTO, verifies it and writes its manifest. It is safe to run while
+// hushd is serving requests.
+func (s *Store) Backup(path string) (*Manifest, error) {
+    if s.db.dialect != sqliteDialect {
+        return nil, ErrBackupUnsupported
+    }
+    if _, err := os.Stat(path); err == nil {
+        return nil, fmt.Errorf("%s already exists", path)
+    }
+
+    if _, err := s.db.Exec("VACUUM INTO ?", path); err != nil {
+        return nil, fmt.Errorf("backup failed: %w", err)
+    }
+
+    m, err := inspect(path)
+    if err != nil {
+        os.Remove(path)
+        return nil, err
+    }
+
+    data, err := json.MarshalIndent(m, "", "  ")
+    if err != nil {
+        return nil, err
+    }
+    if err := os.WriteFile(ManifestPath(path), data, 0600); err != nil {
+        return nil, err
+    }
+    if err := os.Chmod(path, 0600); err != nil {
+        return nil, err
+    }
+
+    return m, nil
+}
+
+// VerifyBackup checks a backup against its manifest: the checksum must
+// match, SQLite's integrity check must pass, row counts must agree and the
+// schema must not be newer than this build understands.
+func VerifyBackup(path string) (*Manifest, error) {
+    data, err := os.ReadFile(ManifestPath(path))
+    if err != nil {
+        return nil, fmt.Errorf("failed to read manifest: %w", err)
+    }
+
+    var want Manifest
+    if err := json.Unmarshal(data, &want); err != nil {
+        return nil, fmt.Errorf("failed to parse manifest: %w", err)
+    }
+
+    got, err := inspect(path)
+    if err != nil {
+        return nil, err
+    }
+
+    if got.SHA256 != want.SHA256 {
+        return nil, errors.New("checksum mismatch: backup file does not match its manifest")
+    }
+    for table, n := range want.Counts {
+        if got.Counts[table] != n {
+            return nil, fmt.Errorf("row count mismatch for %s: manifest has %d, backup has %d", table, n, got.Counts[table])
+        }
+    }
+    if latest := LatestSchemaVersion(); got.SchemaVersion > latest {
+        return nil, fmt.Errorf("backup schema version %d is newer than supported version %d; upgrade hushd first",
+            got.SchemaVersion, latest)
+    }
+
+    got.CreatedAt = want.CreatedAt
+    return got, nil
+}
+
+// inspect opens a database file read-only, checks its integrity and
+// summarises it.
+func inspect(path string) (*Manifest, error) {
+    sum, size, err := checksum(path)
+    if err != nil {
+        return nil, err
+    }
+
+    db, err := sql.Open("sqlite", "file:"+path+"?mode=ro")
+    if err != nil {
+        return nil, err
+    }
+    defer db.Close()
+
+    var result string
+    if err := db.QueryRow("PRAGMA integrity_check").Scan(&result); err != nil {
+        return nil, fmt.Errorf("integrity check failed: %w", err)
+    }
+    if result != "ok" {
+        return nil, fmt.Errorf("integrity check failed: %s", result)
+    }
+
+    version, err := schemaVersion(db)
+    if err != nil {
+        return nil, err
+    }
+
+    m := &Manifest{
+        CreatedAt:     time.Now().UTC().Format(time.RFC3339),
+        SchemaVersion: version,
+        Size:          size,
+        SHA256:        sum,
+        Counts:        make(map[string]int64),
+    }
+
+    for _, table := range tables {
+        var exists int
+        err := db.QueryRow("SELECT COUNT(*) FROM sqlite_master WHERE type = 'table' AND name = ?", table).Scan(&exists)
+        if err != nil {
+            return nil, err
+        }
+        if exists == 0 {
+            if table == "secrets" {
+                return nil, errors.New("not a hush database: secrets table missing")
+            }
+            continue
+        }
+
+        var n int64
+        if err := db.QueryRow("SELECT COUNT(*) FROM " + table).Scan(&n); err != nil {
+            return nil, err
+        }
+        m.Counts[table] = n
+    }
+
+    return m, nil
+}
+
+func checksum(path string) (string, int64, error) {
+    f, err := os.Open(path)
+    if err != nil {
+        return "", 0, err
+    }
+    defer f.Close()
+
+    h := sha256.New()
+    n, err := io.Copy(h, f)
+    if err != nil {
+        return "", 0, err
+    }
+    return hex.EncodeToString(h.Sum(nil)), n, nil
+}
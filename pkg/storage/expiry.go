@@ -0,0 +1,66 @@
+package storage
+
+import (
+    "sort"
+    "time"
+)
+
+// Due returns when the secret should be replaced: the earlier of its
+// expiry date and its last update plus the rotation interval. It reports
+// false for secrets with neither.
+func (s *Secret) Due() (time.Time, bool) {
+    var due time.Time
+    if s.ExpiresAt != "" {
+        if t, err := time.Parse(timeFormat, s.ExpiresAt); err == nil {
+            due = t
+        }
+    }
+    if s.RotateEvery > 0 {
+        if t, err := parseTime(s.UpdatedAt); err == nil {
+            rotate := t.Add(time.Duration(s.RotateEvery) * time.Second)
+            if due.IsZero() || rotate.Before(due) {
+                due = rotate
+            }
+        }
+    }
+    return due, !due.IsZero()
+}
+
+// parseTime reads a timestamp column. The driver hands DATETIME columns
+// back in RFC 3339 form, while those hush writes itself use timeFormat.
+func parseTime(s string) (time.Time, error) {
+    if t, err := time.Parse(timeFormat, s); err == nil {
+        return t, nil
+    }
+    return time.Parse(time.RFC3339, s)
+}
+
+// ExpiringSecrets returns every secret due before the given time, including
+// those already overdue, soonest first. Values are left out.
+func (s *Store) ExpiringSecrets(before time.Time) ([]Secret, error) {
+    rows, err := s.db.Query(`SELECT ` + secretColumns + ` FROM secrets WHERE expires_at != '' OR rotate_every > 0`)
+    if err != nil {
+        return nil, err
+    }
+    defer rows.Close()
+
+    var secrets []Secret
+    for rows.Next() {
+        sec, err := s.scanSecret(rows)
+        if err != nil {
+            return nil, err
+        }
+        if due, ok := sec.Due(); ok && due.Before(before) {
+            sec.Value = ""
+            secrets = append(secrets, *sec)
+        }
+    }
+    sort.SliceStable(secrets, func(i, j int) bool { return secrets[i].DueAt < secrets[j].DueAt })
+    return secrets, rows.Err()
+}
+
+// MarkExpiryNotice records the expiry webhook last sent for a secret.
+func (s *Store) MarkExpiryNotice(id int, notice string) error {
+    _, err := s.db.Exec("UPDATE secrets SET expiry_notice = ? WHERE id = ?", notice, id)
+    return err
+}
@@ -0,0 +1,207 @@
+package storage
+
+import (
+    "database/sql"
+    "embed"
+    "fmt"
+    "io/fs"
+    "sort"
+    "strconv"
+    "strings"
+)
+
+//go:embed migrations
+var migrationFiles embed.FS
+
+// Migration is one ordered schema change, loaded from
+// migrations/<dialect>/NNNN_name.sql. Every dialect has the same versions.
+type Migration struct {
+    Version int
+    Name    string
+    SQL     string
+}
+
+// MigrationState pairs a known migration with when it was applied. Versions
+// found in the database but unknown to this build have an empty Name.
+type MigrationState struct {
+    Migration
+    AppliedAt string
+}
+
+// Applied reports whether the migration has been applied.
+func (m MigrationState) Applied() bool {
+    return m.AppliedAt != ""
+}
+
+var migrations = map[*dialect][]Migration{
+    sqliteDialect:   loadMigrations(sqliteDialect.migrations),
+    postgresDialect: loadMigrations(postgresDialect.migrations),
+}
+
+func init() {
+    for d, list := range migrations {
+        if len(list) != len(migrations[sqliteDialect]) {
+            panic(fmt.Sprintf("storage: %s has %d migrations, sqlite has %d", d.name, len(list), len(migrations[sqliteDialect])))
+        }
+    }
+}
+
+func loadMigrations(dir string) []Migration {
+    entries, err := fs.ReadDir(migrationFiles, dir)
+    if err != nil {
+        panic(err)
+    }
+
+    var list []Migration
+    for _, e := range entries {
+        name := strings.TrimSuffix(e.Name(), ".sql")
+        num, label, ok := strings.Cut(name, "_")
+        version, err := strconv.Atoi(num)
+        if !ok || err != nil {
+            panic("storage: bad migration file name " + e.Name())
+        }
+
+        data, err := migrationFiles.ReadFile(dir + "/" + e.Name())
+        if err != nil {
+            panic(err)
+        }
+        list = append(list, Migration{Version: version, Name: label, SQL: string(data)})
+    }
+
+    sort.Slice(list, func(i, j int) bool { return list[i].Version < list[j].Version })
+    for i, m := range list {
+        if m.Version != i+1 {
+            panic(fmt.Sprintf("storage: %s migrations must be numbered consecutively, found %04d at position %d", dir, m.Version, i+1))
+        }
+    }
+    return list
+}
+
+// LatestSchemaVersion is the newest schema version this build knows about.
+func LatestSchemaVersion() int {
+    return len(migrations[sqliteDialect])
+}
+
+// SchemaVersion reports the newest migration applied to the database.
+func (s *Store) SchemaVersion() (int, error) {
+    return schemaVersion(s.db)
+}
+
+// MigrationStatus lists every known migration and whether it has been
+// applied, followed by any applied versions this build does not know.
+func (s *Store) MigrationStatus() ([]MigrationState, error) {
+    applied, err := appliedMigrations(s.db)
+    if err != nil {
+        return nil, err
+    }
+
+    var states []MigrationState
+    for _, m := range migrations[s.db.dialect] {
+        states = append(states, MigrationState{Migration: m, AppliedAt: applied[m.Version]})
+        delete(applied, m.Version)
+    }
+
+    var unknown []int
+    for v := range applied {
+        unknown = append(unknown, v)
+    }
+    sort.Ints(unknown)
+    for _, v := range unknown {
+        states = append(states, MigrationState{Migration: Migration{Version: v}, AppliedAt: applied[v]})
+    }
+
+    return states, nil
+}
+
+// Migrate applies pending migrations in order, each in its own transaction,
+// and returns the ones it applied. It refuses to touch a database whose
+// schema is newer than this build understands.
+func (s *Store) Migrate() ([]Migration, error) {
+    if _, err := s.db.Exec(s.db.dialect.migrationsTable); err != nil {
+        return nil, err
+    }
+
+    current, err := s.SchemaVersion()
+    if err != nil {
+        return nil, err
+    }
+    if latest := LatestSchemaVersion(); current > latest {
+        return nil, fmt.Errorf("database schema version %d is newer than supported version %d; upgrade hushd", current, latest)
+    }
+
+    var done []Migration
+    for _, m := range migrations[s.db.dialect][current:] {
+        if err := s.apply(m); err != nil {
+            return done, fmt.Errorf("migration %04d_%s failed: %w", m.Version, m.Name, err)
+        }
+        done = append(done, m)
+    }
+
+    return done, nil
+}
+
+func (s *Store) apply(m Migration) error {
+    tx, err := s.db.Begin()
+    if err != nil {
+        return err
+    }
+    defer tx.Rollback()
+
+    if _, err := tx.Exec(m.SQL); err != nil {
+        return err
+    }
+    if _, err := tx.Exec("INSERT INTO schema_migrations (version, name) VALUES (?, ?)", m.Version, m.Name); err != nil {
+        return err
+    }
+
+    return tx.Commit()
+}
+
+type querier interface {
+    QueryRow(query string, args ...any) *sql.Row
+    Query(query string, args ...any) (*sql.Rows, error)
+}
+
+// schemaVersion returns the highest applied migration, or 0 for databases
+// that predate versioned migrations.
+func schemaVersion(db querier) (int, error) {
+    var v sql.NullInt64
+    err := db.QueryRow("SELECT MAX(version) FROM schema_migrations").Scan(&v)
+    if err != nil {
+        if isMissingTable(err) {
+            return 0, nil
+        }
+        return 0, err
+    }
+    return int(v.Int64), nil
+}
+
+func appliedMigrations(db querier) (map[int]string, error) {
+    applied := make(map[int]string)
+
+    rows, err := db.Query("SELECT version, applied_at FROM schema_migrations")
+    if err != nil {
+        if isMissingTable(err) {
+            return applied, nil
+        }
+        return nil, err
+    }
+    defer rows.Close()
+
+    for rows.Next() {
+        var v int
+        var at string
+        if err := rows.Scan(&v, &at); err != nil {
+            return nil, err
+        }
+        applied[v] = at
+    }
+
+    return applied, rows.Err()
+}
+
+// isMissingTable recognises SQLite's and PostgreSQL's "no such table" errors.
+func isMissingTable(err error) bool {
+    msg := err.Error()
+    return strings.Contains(msg, "no such table") || strings.Contains(msg, "does not exist")
+}
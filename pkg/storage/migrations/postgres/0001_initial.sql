@@ -0,0 +1,22 @@
+-- Timestamps are stored as UTC text in the same layout SQLite's
+-- CURRENT_TIMESTAMP produces, so both backends compare and sort them alike.
+
+CREATE TABLE IF NOT EXISTS secrets (
+    id BIGSERIAL PRIMARY KEY,
+    project TEXT NOT NULL,
+    environment TEXT NOT NULL,
+    key TEXT NOT NULL,
+    value TEXT NOT NULL,
+    created_at TEXT NOT NULL DEFAULT to_char(now() AT TIME ZONE 'UTC', 'YYYY-MM-DD HH24:MI:SS'),
+    updated_at TEXT NOT NULL DEFAULT to_char(now() AT TIME ZONE 'UTC', 'YYYY-MM-DD HH24:MI:SS'),
+    UNIQUE(project, environment, key)
+);
+
+CREATE INDEX IF NOT EXISTS idx_project_env ON secrets(project, environment);
+
+CREATE TABLE IF NOT EXISTS tokens (
+    id BIGSERIAL PRIMARY KEY,
+    token TEXT UNIQUE NOT NULL,
+    name TEXT,
+    created_at TEXT NOT NULL DEFAULT to_char(now() AT TIME ZONE 'UTC', 'YYYY-MM-DD HH24:MI:SS')
+);
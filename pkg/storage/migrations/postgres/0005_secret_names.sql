@@ -0,0 +1,3 @@
+-- Client-encrypted display name for projects using opaque keys, where the
+-- key column only holds an HMAC-derived lookup ID.
+ALTER TABLE secrets ADD COLUMN name TEXT NOT NULL DEFAULT '';
@@ -0,0 +1,6 @@
+-- Secret expiry: a fixed date (UTC, "YYYY-MM-DD HH:MM:SS") and/or a rotation
+-- interval in seconds counted from the last update. expiry_notice records
+-- the last expiry webhook sent, so each one is sent once.
+ALTER TABLE secrets ADD COLUMN expires_at TEXT NOT NULL DEFAULT '';
+ALTER TABLE secrets ADD COLUMN rotate_every BIGINT NOT NULL DEFAULT 0;
+ALTER TABLE secrets ADD COLUMN expiry_notice TEXT NOT NULL DEFAULT '';
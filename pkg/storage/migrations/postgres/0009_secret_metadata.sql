@@ -0,0 +1,6 @@
+-- Free-form metadata describing a secret. tags is a comma-separated list.
+-- Opaque-key projects store these encrypted by the client.
+ALTER TABLE secrets ADD COLUMN description TEXT NOT NULL DEFAULT '';
+ALTER TABLE secrets ADD COLUMN owner TEXT NOT NULL DEFAULT '';
+ALTER TABLE secrets ADD COLUMN tags TEXT NOT NULL DEFAULT '';
+ALTER TABLE secrets ADD COLUMN link TEXT NOT NULL DEFAULT '';
@@ -0,0 +1,14 @@
+-- Databases created before versioned migrations already have these tables,
+-- so this migration must stay idempotent.
+
+CREATE TABLE IF NOT EXISTS events (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    project TEXT NOT NULL,
+    environment TEXT NOT NULL,
+    key TEXT NOT NULL,
+    action TEXT NOT NULL,
+    actor TEXT,
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_events_project_env ON events(project, environment, id);
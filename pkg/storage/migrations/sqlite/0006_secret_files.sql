@@ -0,0 +1,4 @@
+-- File secrets: the file name and permissions the client writes the value
+-- out with. Both are empty for ordinary values.
+ALTER TABLE secrets ADD COLUMN file TEXT NOT NULL DEFAULT '';
+ALTER TABLE secrets ADD COLUMN file_mode INTEGER NOT NULL DEFAULT 0;
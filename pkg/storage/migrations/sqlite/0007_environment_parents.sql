@@ -0,0 +1,9 @@
+-- Environment inheritance: secrets not set in an environment are read from
+-- its parent, and so on up the chain.
+CREATE TABLE IF NOT EXISTS environment_parents (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    project TEXT NOT NULL,
+    environment TEXT NOT NULL,
+    parent TEXT NOT NULL,
+    UNIQUE(project, environment)
+);
@@ -0,0 +1,257 @@
+package storage
+
+import (
+    "crypto/aes"
+    "crypto/cipher"
+    "crypto/hmac"
+    "crypto/sha256"
+    "database/sql"
+    "encoding/base64"
+    "errors"
+    "fmt"
+    "strings"
+)
+
+// ServerKeySize is the length of the key used to encrypt metadata at rest.
+const ServerKeySize = 32
+
+// sealedPrefix marks column values encrypted with the server key, so
+// plaintext rows are never mistaken for ciphertext.
+const sealedPrefix = "enc1:"
+
+// keyCheck is sealed and stored in settings to detect a wrong server key.
+const keyCheck = "hush-server-key-check"
+
+var (
+    ErrServerKeyRequired = errors.New("database metadata is encrypted; provide the server key (HUSH_SERVER_KEY or HUSH_SERVER_KEY_FILE)")
+    ErrWrongServerKey    = errors.New("server key does not match the one this database was encrypted with")
+    ErrNotEncrypted      = errors.New("database metadata is not encrypted yet; run 'hushd rekey' to encrypt it with a server key")
+)
+
+// sealedColumns lists every column holding names or other metadata that is
+// encrypted when a server key is configured. New tables with identifying
+// text must be added here so rekeying covers them.
+var sealedColumns = map[string][]string{
+    "secrets":             {"project", "environment", "key", "file", "description", "owner", "tags", "link"},
+    "environment_parents": {"project", "environment", "parent"},
+    "events":              {"project", "environment", "key", "actor"},
+    "tokens":              {"name"},
+    "webhooks":            {"url", "secret", "project", "environment"},
+    "webhook_deliveries":  {"payload"},
+}
+
+// sealer encrypts metadata deterministically (AES-GCM with a nonce derived
+// from an HMAC of the plaintext), so equal names produce equal ciphertext
+// and lookups, upserts and unique constraints keep working.
+type sealer struct {
+    aead cipher.AEAD
+    mac  []byte
+}
+
+func newSealer(key []byte) (*sealer, error) {
+    if len(key) != ServerKeySize {
+        return nil, fmt.Errorf("server key must be %d bytes, got %d", ServerKeySize, len(key))
+    }
+
+    block, err := aes.NewCipher(derive(key, "hush-metadata-enc"))
+    if err != nil {
+        return nil, err
+    }
+    aead, err := cipher.NewGCM(block)
+    if err != nil {
+        return nil, err
+    }
+
+    return &sealer{aead: aead, mac: derive(key, "hush-metadata-nonce")}, nil
+}
+
+func derive(key []byte, label string) []byte {
+    h := hmac.New(sha256.New, key)
+    h.Write([]byte(label))
+    return h.Sum(nil)
+}
+
+func (s *sealer) seal(v string) string {
+    if s == nil || v == "" {
+        return v
+    }
+
+    h := hmac.New(sha256.New, s.mac)
+    h.Write([]byte(v))
+    nonce := h.Sum(nil)[:s.aead.NonceSize()]
+
+    ct := s.aead.Seal(nonce, nonce, []byte(v), nil)
+    return sealedPrefix + base64.RawURLEncoding.EncodeToString(ct)
+}
+
+func (s *sealer) open(v string) (string, error) {
+    if !strings.HasPrefix(v, sealedPrefix) {
+        return v, nil
+    }
+    if s == nil {
+        return "", ErrServerKeyRequired
+    }
+
+    data, err := base64.RawURLEncoding.DecodeString(strings.TrimPrefix(v, sealedPrefix))
+    if err != nil {
+        return "", err
+    }
+    n := s.aead.NonceSize()
+    if len(data) < n {
+        return "", errors.New("sealed value too short")
+    }
+
+    pt, err := s.aead.Open(nil, data[:n], data[n:], nil)
+    if err != nil {
+        return "", ErrWrongServerKey
+    }
+    return string(pt), nil
+}
+
+// openAll opens each pointed-to value in place, stopping at the first error.
+func (s *sealer) openAll(values ...*string) error {
+    for _, v := range values {
+        pt, err := s.open(*v)
+        if err != nil {
+            return err
+        }
+        *v = pt
+    }
+    return nil
+}
+
+// Encrypted reports whether metadata in the database is encrypted.
+func (s *Store) Encrypted() (bool, error) {
+    var v string
+    err := s.db.QueryRow("SELECT value FROM settings WHERE name = ?", "key_check").Scan(&v)
+    if err == sql.ErrNoRows {
+        return false, nil
+    }
+    return err == nil, err
+}
+
+// verifyKey checks the configured server key against the database. A fresh
+// database adopts the key; one holding plaintext metadata must be converted
+// with Rekey first.
+func (s *Store) verifyKey() error {
+    var check string
+    err := s.db.QueryRow("SELECT value FROM settings WHERE name = ?", "key_check").Scan(&check)
+    if err == sql.ErrNoRows {
+        if s.seal == nil {
+            return nil
+        }
+        var rows int
+        if err := s.db.QueryRow("SELECT (SELECT COUNT(*) FROM secrets) + (SELECT COUNT(*) FROM tokens)").Scan(&rows); err != nil {
+            return err
+        }
+        if rows > 0 {
+            return ErrNotEncrypted
+        }
+        _, err := s.db.Exec("INSERT INTO settings (name, value) VALUES (?, ?)", "key_check", s.seal.seal(keyCheck))
+        return err
+    }
+    if err != nil {
+        return err
+    }
+
+    if s.seal == nil {
+        return ErrServerKeyRequired
+    }
+    if v, err := s.seal.open(check); err != nil || v != keyCheck {
+        return ErrWrongServerKey
+    }
+    return nil
+}
+
+// Rekey re-encrypts all metadata with newKey in a single transaction. A nil
+// newKey decrypts everything back to plaintext. The store must have been
+// opened with the current key, if any.
+func (s *Store) Rekey(newKey []byte) error {
+    var next *sealer
+    if newKey != nil {
+        var err error
+        if next, err = newSealer(newKey); err != nil {
+            return err
+        }
+    }
+
+    tx, err := s.db.Begin()
+    if err != nil {
+        return err
+    }
+    defer tx.Rollback()
+
+    for table, cols := range sealedColumns {
+        if err := rekeyTable(tx, table, cols, s.seal, next); err != nil {
+            return fmt.Errorf("rekeying %s: %w", table, err)
+        }
+    }
+
+    if _, err := tx.Exec("DELETE FROM settings WHERE name = ?", "key_check"); err != nil {
+        return err
+    }
+    if next != nil {
+        if _, err := tx.Exec("INSERT INTO settings (name, value) VALUES (?, ?)", "key_check", next.seal(keyCheck)); err != nil {
+            return err
+        }
+    }
+
+    if err := tx.Commit(); err != nil {
+        return err
+    }
+    s.seal = next
+    return nil
+}
+
+func rekeyTable(tx *tx, table string, cols []string, from, to *sealer) error {
+    selects := make([]string, len(cols))
+    sets := make([]string, len(cols))
+    for i, c := range cols {
+        selects[i] = "COALESCE(" + c + ", '')"
+        sets[i] = c + " = ?"
+    }
+
+    rows, err := tx.Query("SELECT id, "+strings.Join(selects, ", ")+" FROM "+table)
+    if err != nil {
+        return err
+    }
+
+    type row struct {
+        id     int64
+        values []string
+    }
+    var all []row
+    for rows.Next() {
+        r := row{values: make([]string, len(cols))}
+        dest := []any{&r.id}
+        for i := range r.values {
+            dest = append(dest, &r.values[i])
+        }
+        if err := rows.Scan(dest...); err != nil {
+            rows.Close()
+            return err
+        }
+        all = append(all, r)
+    }
+    rows.Close()
+    if err := rows.Err(); err != nil {
+        return err
+    }
+
+    update := "UPDATE " + table + " SET " + strings.Join(sets, ", ") + " WHERE id = ?"
+    for _, r := range all {
+        args := make([]any, 0, len(cols)+1)
+        for _, v := range r.values {
+            pt, err := from.open(v)
+            if err != nil {
+                return err
+            }
+            args = append(args, to.seal(pt))
+        }
+        args = append(args, r.id)
+        if _, err := tx.Exec(update, args...); err != nil {
+            return err
+        }
+    }
+    return nil
+}
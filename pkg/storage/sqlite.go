@@ -2,12 +2,37 @@ package storage
 
 import (
     "database/sql"
+    "sort"
+    "strings"
+
     "github.com/google/uuid"
     _ "modernc.org/sqlite"
 )
 
+// Store is the SQL implementation of Backend, used for both SQLite and
+// PostgreSQL.
 type Store struct {
-    db *sql.DB
+    db   *db
+    seal *sealer
+}
+
+// Option configures a Store.
+type Option func(*Store) error
+
+// WithServerKey encrypts project, environment, key and other metadata
+// columns at rest with key, which must be ServerKeySize bytes.
+func WithServerKey(key []byte) Option {
+    return func(s *Store) error {
+        if key == nil {
+            return nil
+        }
+        seal, err := newSealer(key)
+        if err != nil {
+            return err
+        }
+        s.seal = seal
+        return nil
+    }
 }
 
 type Secret struct {
@@ -15,9 +40,33 @@ type Secret struct {
     Project     string
     Environment string
     Key         string
+    // Name is the client-encrypted key name when Key is an opaque lookup
+    // ID, and empty otherwise.
+    Name        string
     Value       string
+    // File and FileMode are set for file secrets, which the client writes
+    // out as a file of that name and permissions.
+    File        string `json:"file,omitempty"`
+    FileMode    int    `json:"file_mode,omitempty"`
     CreatedAt   string
     UpdatedAt   string
+    // ExpiresAt is when the value stops working, in timeFormat, and
+    // RotateEvery how many seconds after each update it is due for
+    // replacement. DueAt is the earlier of the two, filled in on reads.
+    ExpiresAt   string `json:"expires_at,omitempty"`
+    RotateEvery int64  `json:"rotate_every,omitempty"`
+    DueAt       string `json:"due_at,omitempty"`
+    // ExpiryNotice is the last expiry webhook sent for the secret.
+    ExpiryNotice string `json:"-"`
+    // Description, Owner, Tags and Link are free-form notes about the
+    // secret, encrypted by the client in opaque-key projects.
+    Description string   `json:"description,omitempty"`
+    Owner       string   `json:"owner,omitempty"`
+    Tags        []string `json:"tags,omitempty"`
+    Link        string   `json:"link,omitempty"`
+    // InheritedFrom is set by hushd on secrets read through a parent
+    // environment, naming the environment that holds the value.
+    InheritedFrom string `json:"inherited_from,omitempty"`
 }
 
 type Token struct {
@@ -27,69 +76,129 @@ type Token struct {
     CreatedAt string
 }
 
-func New(dbPath string) (*Store, error) {
-    db, err := sql.Open("sqlite", dbPath)
+// Event records a single change to a secret. ID doubles as the revision
+// number handed out to watchers, so it only ever increases.
+type Event struct {
+    ID          int64  `json:"revision"`
+    Project     string `json:"project"`
+    Environment string `json:"environment"`
+    Key         string `json:"key"`
+    Action      string `json:"action"`
+    Actor       string `json:"actor"`
+    CreatedAt   string `json:"created_at"`
+}
+
+const (
+    ActionUpsert = "upsert"
+    ActionDelete = "delete"
+    // ActionReady is not stored: /api/watch sends it once the stream is
+    // subscribed, so a client can re-read what changed before that.
+    ActionReady  = "ready"
+)
+
+// New opens the database selected by dsn and applies any pending
+// migrations. A postgres:// or postgresql:// URL selects PostgreSQL;
+// anything else is the path to a SQLite database file.
+func New(dsn string, opts ...Option) (*Store, error) {
+    store, err := Open(dsn, opts...)
     if err != nil {
         return nil, err
     }
 
-    store := &Store{db: db}
-    if err := store.init(); err != nil {
+    if _, err := store.Migrate(); err != nil {
+        store.Close()
+        return nil, err
+    }
+
+    if err := store.verifyKey(); err != nil {
+        store.Close()
         return nil, err
     }
 
     return store, nil
 }
 
-func (s *Store) init() error {
-    schema := `
-    CREATE TABLE IF NOT EXISTS secrets (
-        id INTEGER PRIMARY KEY AUTOINCREMENT,
-        project TEXT NOT NULL,
-        environment TEXT NOT NULL,
-        key TEXT NOT NULL,
-        value TEXT NOT NULL,
-        created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
-        updated_at DATETIME DEFAULT CURRENT_TIMESTAMP,
-        UNIQUE(project, environment, key)
-    );
-
-    CREATE INDEX IF NOT EXISTS idx_project_env ON secrets(project, environment);
-
-    CREATE TABLE IF NOT EXISTS tokens (
-        id INTEGER PRIMARY KEY AUTOINCREMENT,
-        token TEXT UNIQUE NOT NULL,
-        name TEXT,
-        created_at DATETIME DEFAULT CURRENT_TIMESTAMP
-    );
-    `
+// Open opens the database selected by dsn without migrating it, for
+// tooling that needs to inspect the schema first.
+func Open(dsn string, opts ...Option) (*Store, error) {
+    d := sqliteDialect
+    if IsPostgres(dsn) {
+        d = postgresDialect
+    } else {
+        // hushd writes from request handlers and background workers at the
+        // same time; wait for locks instead of failing with SQLITE_BUSY.
+        sep := "?"
+        if strings.Contains(dsn, "?") {
+            sep = "&"
+        }
+        dsn += sep + "_pragma=busy_timeout(5000)"
+    }
 
-    _, err := s.db.Exec(schema)
-    return err
+    conn, err := sql.Open(d.driver, dsn)
+    if err != nil {
+        return nil, err
+    }
+
+    store := &Store{db: &db{DB: conn, dialect: d}}
+    for _, opt := range opts {
+        if err := opt(store); err != nil {
+            conn.Close()
+            return nil, err
+        }
+    }
+
+    return store, nil
 }
 
 func (s *Store) CreateAdminToken() (string, error) {
     token := "hush_" + uuid.New().String()
-    _, err := s.db.Exec("INSERT INTO tokens (token, name) VALUES (?, ?)", token, "admin")
+    _, err := s.db.Exec("INSERT INTO tokens (token, name) VALUES (?, ?)", token, s.seal.seal("admin"))
     return token, err
 }
 
 func (s *Store) UpsertSecret(secret *Secret) error {
     query := `
-    INSERT INTO secrets (project, environment, key, value, updated_at)
-    VALUES (?, ?, ?, ?, CURRENT_TIMESTAMP)
+    INSERT INTO secrets (project, environment, key, name, value, file, file_mode, expires_at, rotate_every,
+        description, owner, tags, link, updated_at)
+    VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, CURRENT_TIMESTAMP)
     ON CONFLICT(project, environment, key) 
-    DO UPDATE SET value = excluded.value, updated_at = CURRENT_TIMESTAMP
+    DO UPDATE SET name = excluded.name, value = excluded.value, file = excluded.file,
+        file_mode = excluded.file_mode, expires_at = excluded.expires_at,
+        rotate_every = excluded.rotate_every, description = excluded.description,
+        owner = excluded.owner, tags = excluded.tags, link = excluded.link, updated_at = CURRENT_TIMESTAMP
     `
-    _, err := s.db.Exec(query, secret.Project, secret.Environment, secret.Key, secret.Value)
+    _, err := s.db.Exec(query, s.seal.seal(secret.Project), s.seal.seal(secret.Environment), s.seal.seal(secret.Key),
+        secret.Name, secret.Value, s.seal.seal(secret.File), secret.FileMode, secret.ExpiresAt, secret.RotateEvery,
+        s.seal.seal(secret.Description), s.seal.seal(secret.Owner), s.seal.seal(strings.Join(secret.Tags, ",")),
+        s.seal.seal(secret.Link))
     return err
 }
 
 func (s *Store) GetSecrets(project, environment string) ([]Secret, error) {
-    query := `SELECT id, project, environment, key, value, created_at, updated_at 
-              FROM secrets WHERE project = ? AND environment = ?`
+    query := `SELECT ` + secretColumns + ` FROM secrets WHERE project = ? AND environment = ?`
     
-    rows, err := s.db.Query(query, project, environment)
+    rows, err := s.db.Query(query, s.seal.seal(project), s.seal.seal(environment))
+    if err != nil {
+        return nil, err
+    }
+    defer rows.Close()
+
+    var secrets []Secret
+    for rows.Next() {
+        sec, err := s.scanSecret(rows)
+        if err != nil {
+            return nil, err
+        }
+        secrets = append(secrets, *sec)
+    }
+
+    return secrets, rows.Err()
+}
+
+// AllSecrets returns every secret in every project and environment, for
+// searching. Group secrets are included under their "@" project names.
+func (s *Store) AllSecrets() ([]Secret, error) {
+    rows, err := s.db.Query(`SELECT ` + secretColumns + ` FROM secrets ORDER BY id`)
     if err != nil {
         return nil, err
     }
@@ -97,15 +206,148 @@ func (s *Store) GetSecrets(project, environment string) ([]Secret, error) {
 
     var secrets []Secret
     for rows.Next() {
-        var s Secret
-        err := rows.Scan(&s.ID, &s.Project, &s.Environment, &s.Key, &s.Value, &s.CreatedAt, &s.UpdatedAt)
+        sec, err := s.scanSecret(rows)
         if err != nil {
             return nil, err
         }
-        secrets = append(secrets, s)
+        secrets = append(secrets, *sec)
+    }
+    return secrets, rows.Err()
+}
+
+// GetSecret returns a single secret, or sql.ErrNoRows.
+func (s *Store) GetSecret(project, environment, key string) (*Secret, error) {
+    query := `SELECT ` + secretColumns + ` FROM secrets WHERE project = ? AND environment = ? AND key = ?`
+    return s.scanSecret(s.db.QueryRow(query, s.seal.seal(project), s.seal.seal(environment), s.seal.seal(key)))
+}
+
+const secretColumns = `id, project, environment, key, name, value, file, file_mode,
+    expires_at, rotate_every, expiry_notice, description, owner, tags, link, created_at, updated_at`
+
+// scanSecret reads a row selected with secretColumns.
+func (s *Store) scanSecret(row interface{ Scan(...any) error }) (*Secret, error) {
+    var sec Secret
+    var tags string
+    err := row.Scan(&sec.ID, &sec.Project, &sec.Environment, &sec.Key, &sec.Name, &sec.Value, &sec.File, &sec.FileMode,
+        &sec.ExpiresAt, &sec.RotateEvery, &sec.ExpiryNotice, &sec.Description, &sec.Owner, &tags, &sec.Link,
+        &sec.CreatedAt, &sec.UpdatedAt)
+    if err != nil {
+        return nil, err
+    }
+    if err := s.seal.openAll(&sec.Project, &sec.Environment, &sec.Key, &sec.File,
+        &sec.Description, &sec.Owner, &tags, &sec.Link); err != nil {
+        return nil, err
+    }
+    if tags != "" {
+        sec.Tags = strings.Split(tags, ",")
+    }
+    if due, ok := sec.Due(); ok {
+        sec.DueAt = due.Format(timeFormat)
+    }
+    return &sec, nil
+}
+
+func (s *Store) DeleteSecret(project, environment, key string) (bool, error) {
+    res, err := s.db.Exec("DELETE FROM secrets WHERE project = ? AND environment = ? AND key = ?",
+        s.seal.seal(project), s.seal.seal(environment), s.seal.seal(key))
+    if err != nil {
+        return false, err
+    }
+    n, err := res.RowsAffected()
+    return n > 0, err
+}
+
+// GroupPrefix marks the project under which a shared group's secrets are
+// stored: group "smtp" is project "@smtp".
+const GroupPrefix = "@"
+
+func (s *Store) ListGroups() ([]string, error) {
+    rows, err := s.db.Query("SELECT DISTINCT project FROM secrets")
+    if err != nil {
+        return nil, err
+    }
+    defer rows.Close()
+
+    // Projects may be sealed, so the prefix can only be checked once
+    // they are opened.
+    groups := []string{}
+    for rows.Next() {
+        var project string
+        if err := rows.Scan(&project); err != nil {
+            return nil, err
+        }
+        if err := s.seal.openAll(&project); err != nil {
+            return nil, err
+        }
+        if name, ok := strings.CutPrefix(project, GroupPrefix); ok {
+            groups = append(groups, name)
+        }
     }
+    sort.Strings(groups)
+    return groups, rows.Err()
+}
 
-    return secrets, nil
+func (s *Store) GetParent(project, environment string) (string, error) {
+    var parent string
+    err := s.db.QueryRow("SELECT parent FROM environment_parents WHERE project = ? AND environment = ?",
+        s.seal.seal(project), s.seal.seal(environment)).Scan(&parent)
+    if err == sql.ErrNoRows {
+        return "", nil
+    }
+    if err != nil {
+        return "", err
+    }
+    if err := s.seal.openAll(&parent); err != nil {
+        return "", err
+    }
+    return parent, nil
+}
+
+func (s *Store) SetParent(project, environment, parent string) error {
+    if parent == "" {
+        _, err := s.db.Exec("DELETE FROM environment_parents WHERE project = ? AND environment = ?",
+            s.seal.seal(project), s.seal.seal(environment))
+        return err
+    }
+    _, err := s.db.Exec(`INSERT INTO environment_parents (project, environment, parent) VALUES (?, ?, ?)
+        ON CONFLICT(project, environment) DO UPDATE SET parent = excluded.parent`,
+        s.seal.seal(project), s.seal.seal(environment), s.seal.seal(parent))
+    return err
+}
+
+// AppendEvent stores a change event and fills in its revision and timestamp.
+func (s *Store) AppendEvent(e *Event) error {
+    query := `INSERT INTO events (project, environment, key, action, actor)
+              VALUES (?, ?, ?, ?, ?) RETURNING id, created_at`
+    return s.db.QueryRow(query, s.seal.seal(e.Project), s.seal.seal(e.Environment), s.seal.seal(e.Key), e.Action, s.seal.seal(e.Actor)).
+        Scan(&e.ID, &e.CreatedAt)
+}
+
+// EventsSince returns the events for project/environment with a revision
+// greater than after, oldest first.
+func (s *Store) EventsSince(project, environment string, after int64) ([]Event, error) {
+    query := `SELECT id, project, environment, key, action, COALESCE(actor, ''), created_at
+              FROM events WHERE project = ? AND environment = ? AND id > ? ORDER BY id`
+
+    rows, err := s.db.Query(query, s.seal.seal(project), s.seal.seal(environment), after)
+    if err != nil {
+        return nil, err
+    }
+    defer rows.Close()
+
+    var events []Event
+    for rows.Next() {
+        var e Event
+        if err := rows.Scan(&e.ID, &e.Project, &e.Environment, &e.Key, &e.Action, &e.Actor, &e.CreatedAt); err != nil {
+            return nil, err
+        }
+        if err := s.seal.openAll(&e.Project, &e.Environment, &e.Key, &e.Actor); err != nil {
+            return nil, err
+        }
+        events = append(events, e)
+    }
+
+    return events, rows.Err()
 }
 
 func (s *Store) ValidateToken(token string) bool {
@@ -114,6 +356,20 @@ func (s *Store) ValidateToken(token string) bool {
     return err == nil && count > 0
 }
 
+// LookupToken returns the token record matching token, or sql.ErrNoRows.
+func (s *Store) LookupToken(token string) (*Token, error) {
+    var t Token
+    err := s.db.QueryRow("SELECT id, token, COALESCE(name, ''), created_at FROM tokens WHERE token = ?", token).
+        Scan(&t.ID, &t.Token, &t.Name, &t.CreatedAt)
+    if err != nil {
+        return nil, err
+    }
+    if err := s.seal.openAll(&t.Name); err != nil {
+        return nil, err
+    }
+    return &t, nil
+}
+
 func (s *Store) Close() error {
     return s.db.Close()
 }
\ No newline at end of file
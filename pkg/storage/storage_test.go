@@ -0,0 +1,68 @@
+package storage_test
+
+import (
+    "database/sql"
+    "os"
+    "path/filepath"
+    "testing"
+
+    "github.com/adith2005-20/hush/pkg/storage"
+    "github.com/adith2005-20/hush/pkg/storage/storagetest"
+)
+
+func TestSQLite(t *testing.T) {
+    storagetest.Run(t, func(t *testing.T) storage.Backend {
+        store, err := storage.New(filepath.Join(t.TempDir(), "hush.db"))
+        if err != nil {
+            t.Fatal(err)
+        }
+        return store
+    })
+}
+
+// TestSQLiteSealed runs the suite with metadata encrypted at rest.
+func TestSQLiteSealed(t *testing.T) {
+    key := make([]byte, storage.ServerKeySize)
+    storagetest.Run(t, func(t *testing.T) storage.Backend {
+        store, err := storage.New(filepath.Join(t.TempDir(), "hush.db"), storage.WithServerKey(key))
+        if err != nil {
+            t.Fatal(err)
+        }
+        return store
+    })
+}
+
+// TestSQLiteDSNQuery opens a file: DSN that already has a query string.
+func TestSQLiteDSNQuery(t *testing.T) {
+    store, err := storage.New("file:" + filepath.Join(t.TempDir(), "hush.db") + "?mode=rwc")
+    if err != nil {
+        t.Fatal(err)
+    }
+    store.Close()
+}
+
+// TestPostgres needs a disposable database in HUSH_TEST_DATABASE_URL; its
+// public schema is dropped before every subtest.
+func TestPostgres(t *testing.T) {
+    dsn := os.Getenv("HUSH_TEST_DATABASE_URL")
+    if dsn == "" {
+        t.Skip("HUSH_TEST_DATABASE_URL not set")
+    }
+
+    storagetest.Run(t, func(t *testing.T) storage.Backend {
+        conn, err := sql.Open("postgres", dsn)
+        if err != nil {
+            t.Fatal(err)
+        }
+        defer conn.Close()
+        if _, err := conn.Exec("DROP SCHEMA public CASCADE; CREATE SCHEMA public"); err != nil {
+            t.Fatalf("resetting schema: %v", err)
+        }
+
+        store, err := storage.New(dsn)
+        if err != nil {
+            t.Fatal(err)
+        }
+        return store
+    })
+}
@@ -0,0 +1,337 @@
+// Package storagetest is a conformance suite for storage.Backend
+// implementations. Every backend should pass the same suite:
+//
+//	func TestSQLite(t *testing.T) {
+//	    storagetest.Run(t, func(t *testing.T) storage.Backend {
+//	        store, err := storage.New(filepath.Join(t.TempDir(), "hush.db"))
+//	        if err != nil {
+//	            t.Fatal(err)
+//	        }
+//	        return store
+//	    })
+//	}
+//
+// For PostgreSQL, point HUSH_TEST_DATABASE_URL at a disposable local
+// database and skip when it is unset; the suite expects an empty schema.
+package storagetest
+
+import (
+    "database/sql"
+    "testing"
+    "time"
+
+    "github.com/adith2005-20/hush/pkg/storage"
+)
+
+// Run exercises a fresh, migrated backend returned by open for each subtest.
+// open is responsible for isolating subtests from each other.
+func Run(t *testing.T, open func(t *testing.T) storage.Backend) {
+    tests := []struct {
+        name string
+        fn   func(t *testing.T, b storage.Backend)
+    }{
+        {"Secrets", testSecrets},
+        {"Groups", testGroups},
+        {"Parents", testParents},
+        {"Tokens", testTokens},
+        {"Events", testEvents},
+        {"Webhooks", testWebhooks},
+        {"Deliveries", testDeliveries},
+        {"Migrations", testMigrations},
+    }
+
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            b := open(t)
+            defer b.Close()
+            tt.fn(t, b)
+        })
+    }
+}
+
+func testSecrets(t *testing.T, b storage.Backend) {
+    upsert := func(key, value string) {
+        t.Helper()
+        err := b.UpsertSecret(&storage.Secret{Project: "app", Environment: "dev", Key: key, Value: value})
+        if err != nil {
+            t.Fatalf("UpsertSecret(%s): %v", key, err)
+        }
+    }
+
+    upsert("A", "1")
+    upsert("B", "2")
+    upsert("A", "3")
+
+    secrets, err := b.GetSecrets("app", "dev")
+    if err != nil {
+        t.Fatal(err)
+    }
+    got := map[string]string{}
+    for _, s := range secrets {
+        got[s.Key] = s.Value
+        if s.CreatedAt == "" || s.UpdatedAt == "" {
+            t.Errorf("secret %s missing timestamps", s.Key)
+        }
+    }
+    if len(got) != 2 || got["A"] != "3" || got["B"] != "2" {
+        t.Fatalf("GetSecrets = %v, want A=3 B=2", got)
+    }
+
+    if sec, err := b.GetSecret("app", "dev", "A"); err != nil || sec.Value != "3" {
+        t.Fatalf("GetSecret(A) = %v, %v; want value 3", sec, err)
+    }
+    file := &storage.Secret{Project: "app", Environment: "dev", Key: "CERT", Value: "pem", File: "tls.crt", FileMode: 0400}
+    if err := b.UpsertSecret(file); err != nil {
+        t.Fatalf("UpsertSecret(CERT): %v", err)
+    }
+    if sec, err := b.GetSecret("app", "dev", "CERT"); err != nil || sec.File != "tls.crt" || sec.FileMode != 0400 {
+        t.Fatalf("GetSecret(CERT) = %v, %v; want file tls.crt mode 0400", sec, err)
+    }
+    if _, err := b.DeleteSecret("app", "dev", "CERT"); err != nil {
+        t.Fatal(err)
+    }
+    noted := &storage.Secret{Project: "app", Environment: "dev", Key: "STRIPE", Value: "v", Description: "Live key",
+        Owner: "payments-team", Tags: []string{"payments", "vendor"}, Link: "https://dashboard.stripe.com"}
+    if err := b.UpsertSecret(noted); err != nil {
+        t.Fatalf("UpsertSecret(STRIPE): %v", err)
+    }
+    sec, err := b.GetSecret("app", "dev", "STRIPE")
+    if err != nil || sec.Description != noted.Description || sec.Owner != noted.Owner || sec.Link != noted.Link ||
+        len(sec.Tags) != 2 || sec.Tags[0] != "payments" || sec.Tags[1] != "vendor" {
+        t.Fatalf("GetSecret(STRIPE) = %+v, %v; want the metadata it was stored with", sec, err)
+    }
+    if _, err := b.DeleteSecret("app", "dev", "STRIPE"); err != nil {
+        t.Fatal(err)
+    }
+
+    if _, err := b.GetSecret("app", "dev", "missing"); err != sql.ErrNoRows {
+        t.Errorf("GetSecret(missing) error = %v, want sql.ErrNoRows", err)
+    }
+
+    if other, _ := b.GetSecrets("app", "prod"); len(other) != 0 {
+        t.Errorf("environments leak into each other: %v", other)
+    }
+
+    if err := b.UpsertSecret(&storage.Secret{Project: "api", Environment: "prod", Key: "A", Value: "4"}); err != nil {
+        t.Fatalf("UpsertSecret(api/prod/A): %v", err)
+    }
+    all, err := b.AllSecrets()
+    if err != nil {
+        t.Fatal(err)
+    }
+    if len(all) != 3 || all[2].Project != "api" || all[2].Environment != "prod" || all[2].Value != "4" {
+        t.Fatalf("AllSecrets = %v, want app/dev A and B then api/prod A", all)
+    }
+    if _, err := b.DeleteSecret("api", "prod", "A"); err != nil {
+        t.Fatal(err)
+    }
+
+    deleted, err := b.DeleteSecret("app", "dev", "A")
+    if err != nil || !deleted {
+        t.Fatalf("DeleteSecret = %v, %v; want true, nil", deleted, err)
+    }
+    deleted, err = b.DeleteSecret("app", "dev", "A")
+    if err != nil || deleted {
+        t.Fatalf("second DeleteSecret = %v, %v; want false, nil", deleted, err)
+    }
+}
+
+func testGroups(t *testing.T, b storage.Backend) {
+    for _, project := range []string{"app", "@smtp", "@sentry", "@smtp"} {
+        err := b.UpsertSecret(&storage.Secret{Project: project, Environment: "dev", Key: "K", Value: "v"})
+        if err != nil {
+            t.Fatalf("UpsertSecret(%s): %v", project, err)
+        }
+    }
+
+    groups, err := b.ListGroups()
+    if err != nil {
+        t.Fatal(err)
+    }
+    if len(groups) != 2 || groups[0] != "sentry" || groups[1] != "smtp" {
+        t.Fatalf("ListGroups = %v, want [sentry smtp]", groups)
+    }
+}
+
+func testParents(t *testing.T, b storage.Backend) {
+    if parent, err := b.GetParent("app", "staging"); err != nil || parent != "" {
+        t.Fatalf("GetParent before SetParent = %q, %v; want \"\", nil", parent, err)
+    }
+
+    for _, parent := range []string{"base", "production"} {
+        if err := b.SetParent("app", "staging", parent); err != nil {
+            t.Fatalf("SetParent(%s): %v", parent, err)
+        }
+        if got, err := b.GetParent("app", "staging"); err != nil || got != parent {
+            t.Fatalf("GetParent = %q, %v; want %q", got, err, parent)
+        }
+    }
+    if parent, _ := b.GetParent("other", "staging"); parent != "" {
+        t.Errorf("parents leak between projects: %q", parent)
+    }
+
+    if err := b.SetParent("app", "staging", ""); err != nil {
+        t.Fatal(err)
+    }
+    if parent, err := b.GetParent("app", "staging"); err != nil || parent != "" {
+        t.Fatalf("GetParent after clearing = %q, %v; want \"\", nil", parent, err)
+    }
+}
+
+func testTokens(t *testing.T, b storage.Backend) {
+    token, err := b.CreateAdminToken()
+    if err != nil {
+        t.Fatal(err)
+    }
+
+    if !b.ValidateToken(token) {
+        t.Error("ValidateToken rejected a fresh admin token")
+    }
+    if b.ValidateToken("hush_nope") {
+        t.Error("ValidateToken accepted an unknown token")
+    }
+
+    rec, err := b.LookupToken(token)
+    if err != nil {
+        t.Fatal(err)
+    }
+    if rec.Name != "admin" {
+        t.Errorf("token name = %q, want admin", rec.Name)
+    }
+    if _, err := b.LookupToken("hush_nope"); err != sql.ErrNoRows {
+        t.Errorf("LookupToken(unknown) error = %v, want sql.ErrNoRows", err)
+    }
+}
+
+func testEvents(t *testing.T, b storage.Backend) {
+    var revs []int64
+    for _, key := range []string{"A", "B", "C"} {
+        e := &storage.Event{Project: "app", Environment: "dev", Key: key, Action: storage.ActionUpsert, Actor: "ci"}
+        if err := b.AppendEvent(e); err != nil {
+            t.Fatal(err)
+        }
+        revs = append(revs, e.ID)
+    }
+    if err := b.AppendEvent(&storage.Event{Project: "app", Environment: "prod", Key: "X", Action: storage.ActionDelete}); err != nil {
+        t.Fatal(err)
+    }
+
+    if !(revs[0] < revs[1] && revs[1] < revs[2]) {
+        t.Fatalf("revisions not increasing: %v", revs)
+    }
+
+    events, err := b.EventsSince("app", "dev", revs[0])
+    if err != nil {
+        t.Fatal(err)
+    }
+    if len(events) != 2 || events[0].Key != "B" || events[1].Key != "C" || events[0].Actor != "ci" {
+        t.Fatalf("EventsSince = %+v, want B then C", events)
+    }
+}
+
+func testWebhooks(t *testing.T, b storage.Backend) {
+    w := &storage.Webhook{URL: "https://example.com/hook", Secret: "s", Project: "app", Events: []string{"secret.updated"}}
+    if err := b.CreateWebhook(w); err != nil {
+        t.Fatal(err)
+    }
+    if w.ID == 0 {
+        t.Fatal("CreateWebhook did not assign an id")
+    }
+
+    got, err := b.GetWebhook(w.ID)
+    if err != nil {
+        t.Fatal(err)
+    }
+    if got.URL != w.URL || got.Project != "app" || len(got.Events) != 1 || got.Events[0] != "secret.updated" {
+        t.Errorf("GetWebhook = %+v", got)
+    }
+
+    hooks, err := b.ListWebhooks()
+    if err != nil || len(hooks) != 1 {
+        t.Fatalf("ListWebhooks = %v, %v", hooks, err)
+    }
+
+    removed, err := b.DeleteWebhook(w.ID)
+    if err != nil || !removed {
+        t.Fatalf("DeleteWebhook = %v, %v", removed, err)
+    }
+    if _, err := b.GetWebhook(w.ID); err != sql.ErrNoRows {
+        t.Errorf("GetWebhook after delete error = %v, want sql.ErrNoRows", err)
+    }
+}
+
+func testDeliveries(t *testing.T, b storage.Backend) {
+    w := &storage.Webhook{URL: "https://example.com/hook", Secret: "s", Events: []string{"*"}}
+    if err := b.CreateWebhook(w); err != nil {
+        t.Fatal(err)
+    }
+
+    n, err := b.EnqueueDeliveries("secret.updated", "app", "dev", `{"key":"A"}`)
+    if err != nil || n != 1 {
+        t.Fatalf("EnqueueDeliveries = %d, %v; want 1, nil", n, err)
+    }
+
+    now := time.Now().Add(time.Second)
+    due, err := b.DueDeliveries(now, 10)
+    if err != nil || len(due) != 1 {
+        t.Fatalf("DueDeliveries = %v, %v", due, err)
+    }
+    d := due[0]
+    if d.URL != w.URL || d.Payload != `{"key":"A"}` {
+        t.Errorf("delivery = %+v", d)
+    }
+
+    if err := b.MarkFailed(d.ID, "boom", now.Add(time.Hour)); err != nil {
+        t.Fatal(err)
+    }
+    if due, _ := b.DueDeliveries(now, 10); len(due) != 0 {
+        t.Errorf("delivery still due after backoff: %v", due)
+    }
+    if due, _ := b.DueDeliveries(now.Add(2*time.Hour), 10); len(due) != 1 || due[0].Attempts != 1 {
+        t.Errorf("delivery not due after backoff: %v", due)
+    }
+
+    if err := b.MarkFailed(d.ID, "boom", time.Time{}); err != nil {
+        t.Fatal(err)
+    }
+    dead, err := b.DeadDeliveries()
+    if err != nil || len(dead) != 1 || dead[0].LastError != "boom" {
+        t.Fatalf("DeadDeliveries = %v, %v", dead, err)
+    }
+
+    ok, err := b.RetryDelivery(d.ID)
+    if err != nil || !ok {
+        t.Fatalf("RetryDelivery = %v, %v", ok, err)
+    }
+    if err := b.MarkDelivered(d.ID); err != nil {
+        t.Fatal(err)
+    }
+    if due, _ := b.DueDeliveries(now.Add(2*time.Hour), 10); len(due) != 0 {
+        t.Errorf("delivered delivery still due: %v", due)
+    }
+}
+
+func testMigrations(t *testing.T, b storage.Backend) {
+    version, err := b.SchemaVersion()
+    if err != nil {
+        t.Fatal(err)
+    }
+    if version != storage.LatestSchemaVersion() {
+        t.Errorf("SchemaVersion = %d, want %d", version, storage.LatestSchemaVersion())
+    }
+
+    applied, err := b.Migrate()
+    if err != nil || len(applied) != 0 {
+        t.Errorf("re-running Migrate = %v, %v; want nothing applied", applied, err)
+    }
+
+    states, err := b.MigrationStatus()
+    if err != nil {
+        t.Fatal(err)
+    }
+    for _, s := range states {
+        if !s.Applied() {
+            t.Errorf("migration %04d_%s not applied", s.Version, s.Name)
+        }
+    }
+}
@@ -0,0 +1,236 @@
+package storage
+
+import (
+    "database/sql"
+    "strings"
+    "time"
+)
+
+// Webhook is an outbound subscription to secret change events. An empty
+// Project or Environment matches every project or environment.
+type Webhook struct {
+    ID          int64
+    URL         string
+    Secret      string
+    Project     string
+    Environment string
+    Events      []string
+    CreatedAt   string
+}
+
+// Delivery is one attempt-tracked send of an event payload to a webhook.
+type Delivery struct {
+    ID            int64
+    WebhookID     int64
+    URL           string
+    Secret        string
+    Event         string
+    Payload       string
+    Status        string
+    Attempts      int
+    LastError     string
+    NextAttemptAt string
+    CreatedAt     string
+}
+
+const (
+    DeliveryPending   = "pending"
+    DeliveryDelivered = "delivered"
+    DeliveryDead      = "dead"
+)
+
+// timeFormat matches SQLite's CURRENT_TIMESTAMP so stored times compare
+// correctly as text.
+const timeFormat = "2006-01-02 15:04:05"
+
+// Matches reports whether the webhook subscribes to event for project/env.
+func (w *Webhook) Matches(event, project, env string) bool {
+    if w.Project != "" && w.Project != project {
+        return false
+    }
+    if w.Environment != "" && w.Environment != env {
+        return false
+    }
+    for _, e := range w.Events {
+        if e == "*" || e == event {
+            return true
+        }
+    }
+    return false
+}
+
+func (s *Store) CreateWebhook(w *Webhook) error {
+    query := `INSERT INTO webhooks (url, secret, project, environment, events)
+              VALUES (?, ?, ?, ?, ?) RETURNING id, created_at`
+    return s.db.QueryRow(query, s.seal.seal(w.URL), s.seal.seal(w.Secret), s.seal.seal(w.Project), s.seal.seal(w.Environment),
+        strings.Join(w.Events, ",")).
+        Scan(&w.ID, &w.CreatedAt)
+}
+
+func (s *Store) ListWebhooks() ([]Webhook, error) {
+    rows, err := s.db.Query(`SELECT id, url, secret, project, environment, events, created_at
+                             FROM webhooks ORDER BY id`)
+    if err != nil {
+        return nil, err
+    }
+    defer rows.Close()
+
+    var hooks []Webhook
+    for rows.Next() {
+        w, err := s.scanWebhook(rows)
+        if err != nil {
+            return nil, err
+        }
+        hooks = append(hooks, *w)
+    }
+
+    return hooks, rows.Err()
+}
+
+// GetWebhook returns the webhook with the given id, or sql.ErrNoRows.
+func (s *Store) GetWebhook(id int64) (*Webhook, error) {
+    row := s.db.QueryRow(`SELECT id, url, secret, project, environment, events, created_at
+                          FROM webhooks WHERE id = ?`, id)
+    return s.scanWebhook(row)
+}
+
+// DeleteWebhook removes a webhook together with its pending and dead deliveries.
+func (s *Store) DeleteWebhook(id int64) (bool, error) {
+    tx, err := s.db.Begin()
+    if err != nil {
+        return false, err
+    }
+    defer tx.Rollback()
+
+    if _, err := tx.Exec("DELETE FROM webhook_deliveries WHERE webhook_id = ?", id); err != nil {
+        return false, err
+    }
+    res, err := tx.Exec("DELETE FROM webhooks WHERE id = ?", id)
+    if err != nil {
+        return false, err
+    }
+    n, err := res.RowsAffected()
+    if err != nil {
+        return false, err
+    }
+
+    return n > 0, tx.Commit()
+}
+
+// EnqueueDeliveries queues payload for every webhook subscribed to event in
+// project/env and returns how many deliveries were queued.
+func (s *Store) EnqueueDeliveries(event, project, env, payload string) (int, error) {
+    hooks, err := s.ListWebhooks()
+    if err != nil {
+        return 0, err
+    }
+
+    queued := 0
+    for _, w := range hooks {
+        if !w.Matches(event, project, env) {
+            continue
+        }
+        _, err := s.db.Exec("INSERT INTO webhook_deliveries (webhook_id, event, payload) VALUES (?, ?, ?)",
+            w.ID, event, s.seal.seal(payload))
+        if err != nil {
+            return queued, err
+        }
+        queued++
+    }
+
+    return queued, nil
+}
+
+// DueDeliveries returns up to limit pending deliveries whose next attempt is
+// at or before now, oldest first.
+func (s *Store) DueDeliveries(now time.Time, limit int) ([]Delivery, error) {
+    query := `SELECT d.id, d.webhook_id, w.url, w.secret, d.event, d.payload, d.status,
+                     d.attempts, d.last_error, d.next_attempt_at, d.created_at
+              FROM webhook_deliveries d JOIN webhooks w ON w.id = d.webhook_id
+              WHERE d.status = ? AND d.next_attempt_at <= ?
+              ORDER BY d.id LIMIT ?`
+    return s.queryDeliveries(query, DeliveryPending, now.UTC().Format(timeFormat), limit)
+}
+
+// DeadDeliveries returns the dead-letter list: deliveries that exhausted
+// their retries.
+func (s *Store) DeadDeliveries() ([]Delivery, error) {
+    query := `SELECT d.id, d.webhook_id, w.url, w.secret, d.event, d.payload, d.status,
+                     d.attempts, d.last_error, d.next_attempt_at, d.created_at
+              FROM webhook_deliveries d JOIN webhooks w ON w.id = d.webhook_id
+              WHERE d.status = ? ORDER BY d.id`
+    return s.queryDeliveries(query, DeliveryDead)
+}
+
+func (s *Store) MarkDelivered(id int64) error {
+    _, err := s.db.Exec("UPDATE webhook_deliveries SET status = ?, attempts = attempts + 1, last_error = '' WHERE id = ?",
+        DeliveryDelivered, id)
+    return err
+}
+
+// MarkFailed records a failed attempt. If next is zero the delivery is moved
+// to the dead-letter list, otherwise it is retried at next.
+func (s *Store) MarkFailed(id int64, reason string, next time.Time) error {
+    if next.IsZero() {
+        _, err := s.db.Exec("UPDATE webhook_deliveries SET status = ?, attempts = attempts + 1, last_error = ? WHERE id = ?",
+            DeliveryDead, reason, id)
+        return err
+    }
+    _, err := s.db.Exec(`UPDATE webhook_deliveries SET attempts = attempts + 1, last_error = ?, next_attempt_at = ?
+                         WHERE id = ?`, reason, next.UTC().Format(timeFormat), id)
+    return err
+}
+
+// RetryDelivery moves a dead delivery back to the queue with a fresh set of attempts.
+func (s *Store) RetryDelivery(id int64) (bool, error) {
+    res, err := s.db.Exec(`UPDATE webhook_deliveries SET status = ?, attempts = 0, next_attempt_at = CURRENT_TIMESTAMP
+                           WHERE id = ? AND status = ?`, DeliveryPending, id, DeliveryDead)
+    if err != nil {
+        return false, err
+    }
+    n, err := res.RowsAffected()
+    return n > 0, err
+}
+
+type scanner interface {
+    Scan(dest ...any) error
+}
+
+func (s *Store) scanWebhook(row scanner) (*Webhook, error) {
+    var w Webhook
+    var events string
+    if err := row.Scan(&w.ID, &w.URL, &w.Secret, &w.Project, &w.Environment, &events, &w.CreatedAt); err != nil {
+        return nil, err
+    }
+    if err := s.seal.openAll(&w.URL, &w.Secret, &w.Project, &w.Environment); err != nil {
+        return nil, err
+    }
+    w.Events = strings.Split(events, ",")
+    return &w, nil
+}
+
+func (s *Store) queryDeliveries(query string, args ...any) ([]Delivery, error) {
+    rows, err := s.db.Query(query, args...)
+    if err != nil {
+        return nil, err
+    }
+    defer rows.Close()
+
+    var deliveries []Delivery
+    for rows.Next() {
+        var d Delivery
+        var next, created sql.NullString
+        err := rows.Scan(&d.ID, &d.WebhookID, &d.URL, &d.Secret, &d.Event, &d.Payload, &d.Status,
+            &d.Attempts, &d.LastError, &next, &created)
+        if err != nil {
+            return nil, err
+        }
+        d.NextAttemptAt, d.CreatedAt = next.String, created.String
+        if err := s.seal.openAll(&d.URL, &d.Secret, &d.Payload); err != nil {
+            return nil, err
+        }
+        deliveries = append(deliveries, d)
+    }
+
+    return deliveries, rows.Err()
+}